@@ -0,0 +1,79 @@
+package jsonr
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// bytesType the type of []byte, named "bytes" in type names
+var bytesType = reflect.TypeFor[[]byte]()
+
+// BytesEncoding defines how byte slices are written to JSON
+type BytesEncoding int
+
+const (
+	// BytesBase64 writes byte slices as base64 strings, like encoding/json does
+	BytesBase64 BytesEncoding = iota
+	// BytesHex writes byte slices as hexadecimal strings
+	BytesHex
+)
+
+// MarshalBytesEncoding sets how byte slices are written, the default is BytesBase64
+func MarshalBytesEncoding(encoding BytesEncoding) MarshalOption {
+	return func(opts *marshalOptions) error {
+		if encoding != BytesBase64 && encoding != BytesHex {
+			return fmt.Errorf("unknown bytes encoding %d", encoding)
+		}
+		opts.bytesEncoding = encoding
+		return nil
+	}
+}
+
+// WithBytesEncoding sets how byte slices are read, it should match the encoding used with MarshalBytesEncoding
+func WithBytesEncoding(encoding BytesEncoding) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if encoding != BytesBase64 && encoding != BytesHex {
+			return fmt.Errorf("unknown bytes encoding %d", encoding)
+		}
+		opts.bytesEncoding = encoding
+		return nil
+	}
+}
+
+// isByteSlice reports if values of the type are written as a string, like encoding/json does for slices of bytes
+func isByteSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && !isOpaque(t) && !isOpaque(t.Elem())
+}
+
+// marshalBytes returns the byte slice as a value that is written as a string using the encoding
+func marshalBytes(v reflect.Value, encoding BytesEncoding) any {
+	if v.IsNil() {
+		return nil
+	}
+	if encoding == BytesHex {
+		return hex.EncodeToString(v.Bytes())
+	}
+	return v.Bytes()
+}
+
+// unmarshalBytes decodes the string found at the JSON path into the byte slice v using the encoding
+func unmarshalBytes(raw json.RawMessage, v reflect.Value, encoding BytesEncoding, path string) error {
+	var b []byte
+	if encoding == BytesHex {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Errorf("error unmarshalling bytes at %s: %s", path, err.Error())
+		}
+		decoded, err := hex.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("error unmarshalling bytes at %s: %s", path, err.Error())
+		}
+		b = decoded
+	} else if err := json.Unmarshal(raw, &b); err != nil {
+		return fmt.Errorf("error unmarshalling bytes at %s: %s", path, err.Error())
+	}
+	v.SetBytes(b)
+	return nil
+}
@@ -0,0 +1,48 @@
+package jsonr
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// UnknownTypeError is returned when a type name found in the JSON can not be resolved into a type, because it is
+// not registered or is not a valid type name.
+type UnknownTypeError struct {
+	// Type is the type name as found in the JSON
+	Type string
+	// Path is the JSON path of the value with the unknown type, e.g. $.v.payload
+	Path string
+}
+
+func (e *UnknownTypeError) Error() string {
+	return fmt.Sprintf("unknown type %q at %s", e.Type, e.Path)
+}
+
+// TypeMismatchError is returned by UnmarshalAs when the type found in the JSON can not be returned as the requested
+// type.
+type TypeMismatchError struct {
+	// Type is the type name as found in the JSON
+	Type string
+	// Expected is the requested type
+	Expected reflect.Type
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("type %q can not be unmarshalled as %s", e.Type, e.Expected)
+}
+
+// LimitError is returned when the data exceeds one of the limits set by WithMaxDepth, WithMaxBytes,
+// WithMaxElements, WithMaxStringLength or WithMaxTypeDepth. The data is rejected before it is decoded.
+type LimitError struct {
+	// Limit is the name of the limit that was exceeded: "depth", "bytes", "elements", "string length",
+	// "type depth" or "type size"
+	Limit string
+	// Max is the configured maximum
+	Max int
+	// Path is the JSON path of the value exceeding the limit, e.g. $.v[3]
+	Path string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("max %s of %d exceeded at %s", e.Limit, e.Max, e.Path)
+}
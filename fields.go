@@ -0,0 +1,309 @@
+package jsonr
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// field describes a struct field as it is seen by encoding/json
+type field struct {
+	name      string
+	tagged    bool
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+	quoted    bool
+}
+
+// fieldCache caches the resolved fields of struct types
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// cachedFields returns the json fields of the struct type t, following the same rules as encoding/json for
+// tags, unexported fields and embedded structs.
+func cachedFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.([]field)
+}
+
+// typeFields walks the struct type t breadth first, promoting the fields of embedded structs
+func typeFields(t reflect.Type) []field {
+	var fields []field
+	current := []field{}
+	next := []field{{typ: t}}
+	visited := map[reflect.Type]bool{}
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				if sf.Anonymous {
+					ft := sf.Type
+					if ft.Kind() == reflect.Ptr {
+						ft = ft.Elem()
+					}
+					// Unexported embedded non-structs are ignored, like encoding/json does
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, tagOpts, _ := strings.Cut(tag, ",")
+
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				// Untagged embedded structs get their fields promoted to the next level
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, field{name: ft.Name(), index: index, typ: ft})
+					continue
+				}
+
+				fields = append(fields, field{
+					name:      nameOrDefault(name, sf.Name),
+					tagged:    name != "",
+					index:     index,
+					typ:       sf.Type,
+					omitEmpty: hasTagOption(tagOpts, "omitempty"),
+					quoted:    hasTagOption(tagOpts, "string") && isQuotable(sf.Type),
+				})
+			}
+		}
+	}
+
+	// Group the fields by name, shallowest and tagged fields first
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].name != fields[j].name {
+			return fields[i].name < fields[j].name
+		}
+		if len(fields[i].index) != len(fields[j].index) {
+			return len(fields[i].index) < len(fields[j].index)
+		}
+		return fields[i].tagged && !fields[j].tagged
+	})
+
+	// Keep the dominant field for every name, dropping ambiguous ones
+	out := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if dominant, ok := dominantField(fields[i:j]); ok {
+			out = append(out, dominant)
+		}
+		i = j
+	}
+	fields = out
+
+	// Restore the declaration order
+	sort.Slice(fields, func(i, j int) bool {
+		x, y := fields[i].index, fields[j].index
+		for k := 0; k < len(x) && k < len(y); k++ {
+			if x[k] != y[k] {
+				return x[k] < y[k]
+			}
+		}
+		return len(x) < len(y)
+	})
+	return fields
+}
+
+// dominantField returns the field that wins from a group of fields with the same name
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+// fieldByName finds a field by its json name, falling back to a case-insensitive match like encoding/json
+func fieldByName(fields []field, name string) *field {
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, name) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// fieldByIndex returns the field at the index path, allocating nil embedded struct pointers when alloc is set.
+// It returns an invalid value when a nil embedded pointer can not be followed.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func nameOrDefault(name, def string) string {
+	if name == "" {
+		return def
+	}
+	return name
+}
+
+func hasTagOption(tagOpts, option string) bool {
+	for tagOpts != "" {
+		var opt string
+		opt, tagOpts, _ = strings.Cut(tagOpts, ",")
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isQuotable reports if the ",string" tag option applies to the type
+func isQuotable(t reflect.Type) bool {
+	if t.Name() == "" && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	default:
+		return false
+	}
+}
+
+// isEmptyValue reports if the value is considered empty for the "omitempty" tag option
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	default:
+		return false
+	}
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeFor[json.Marshaler]()
+	jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+)
+
+// isOpaque reports if the type, or a pointer to it, implements json.Marshaler, json.Unmarshaler,
+// encoding.TextMarshaler or encoding.TextUnmarshaler. Values of these types are handled by their own methods and
+// are never walked.
+func isOpaque(t reflect.Type) bool {
+	if t.Kind() == reflect.Interface {
+		return false
+	}
+	for _, it := range []reflect.Type{jsonMarshalerType, textMarshalerType, jsonUnmarshalerType, textUnmarshalerType} {
+		if t.Implements(it) || reflect.PointerTo(t).Implements(it) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPointerMarshaler reports if only a pointer to the type implements json.Marshaler or encoding.TextMarshaler,
+// encoding/json only uses such methods for addressable values
+func hasPointerMarshaler(t reflect.Type) bool {
+	if t.Kind() == reflect.Interface || t.Kind() == reflect.Ptr {
+		return false
+	}
+	pt := reflect.PointerTo(t)
+	return (pt.Implements(jsonMarshalerType) && !t.Implements(jsonMarshalerType)) ||
+		(pt.Implements(textMarshalerType) && !t.Implements(textMarshalerType))
+}
+
+// wrapCache caches if values of a type needs to be walked while wrapping
+var wrapCache sync.Map // map[reflect.Type]bool
+
+// needsWrapping reports if the type contains interface values at any depth, which require their own type
+// information when marshalled, maps with keys that can not be represented as JSON object keys, complex numbers, or
+// values that only marshal themselves with a pointer receiver.
+func needsWrapping(t reflect.Type) bool {
+	if b, ok := wrapCache.Load(t); ok {
+		return b.(bool)
+	}
+	b := requiresWrapping(t, map[reflect.Type]bool{})
+	wrapCache.Store(t, b)
+	return b
+}
+
+// requiresWrapping walks the type to find interfaces or non-textual map keys, seen guards against recursive types
+func requiresWrapping(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	// Values that marshal themselves are only walked to make them addressable for their pointer methods
+	if isOpaque(t) {
+		return hasPointerMarshaler(t)
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Complex64, reflect.Complex128:
+		// Complex numbers are not supported by encoding/json, they are written as [real, imag]
+		return true
+	case reflect.Struct:
+		// URLs are walked to be written as string with MarshalStdlibTypes
+		if t == urlType {
+			return true
+		}
+		for _, f := range cachedFields(t) {
+			if requiresWrapping(f.typ, seen) {
+				return true
+			}
+		}
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		// Byte slices are walked to be written with the bytes encoding
+		return isByteSlice(t) || requiresWrapping(t.Elem(), seen)
+	case reflect.Map:
+		return !isJSONKey(t.Key()) || requiresWrapping(t.Key(), seen) || requiresWrapping(t.Elem(), seen)
+	default:
+	}
+	return false
+}
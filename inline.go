@@ -0,0 +1,102 @@
+package jsonr
+
+import (
+	"reflect"
+	"sort"
+)
+
+// canInline reports if values of the type can be marshalled inline, with the type key as a sibling of their
+// members. Only structs and maps with textual keys, or pointers to them, are JSON objects that can hold the type key.
+// Types that marshal themselves are never inlined.
+func canInline(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if isOpaque(t) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return true
+	case reflect.Map:
+		return isTextualKey(t.Key())
+	default:
+		return false
+	}
+}
+
+// inlineObject returns the members of a struct or map value that can be marshalled inline. It reports false when
+// the value can not be inlined, like nil values or values with a member using the type or value key.
+func inlineObject(v reflect.Value, opts *marshalOptions) (object, bool, error) {
+	if !canInline(v.Type()) {
+		return object{}, false, nil
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return object{}, false, nil
+		}
+		if err := opts.cycles.enter(v); err != nil {
+			return object{}, false, err
+		}
+		defer opts.cycles.leave(v)
+		v = v.Elem()
+	}
+	if opts.stdlib && v.Type() == urlType {
+		return object{}, false, nil
+	}
+
+	var o object
+	var err error
+	switch v.Kind() {
+	case reflect.Struct:
+		if o, err = wrapStruct(v, opts); err != nil {
+			return object{}, false, err
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return object{}, false, nil
+		}
+		if err := opts.cycles.enter(v); err != nil {
+			return object{}, false, err
+		}
+		defer opts.cycles.leave(v)
+		if o, err = inlineMap(v, opts); err != nil {
+			return object{}, false, err
+		}
+	}
+
+	for _, m := range o.members {
+		if m.name == opts.typeKey || m.name == opts.valueKey {
+			return object{}, false, nil
+		}
+	}
+	return o, true, nil
+}
+
+// inlineMap rebuilds a map with textual keys as an object with its members sorted by key, like encoding/json
+func inlineMap(v reflect.Value, opts *marshalOptions) (object, error) {
+	o := object{
+		members:    make([]member, 0, v.Len()),
+		escapeHTML: opts.escapeHTML,
+	}
+	iter := v.MapRange()
+	for iter.Next() {
+		mv := iter.Value()
+		if opts.omitNil && mv.Kind() == reflect.Interface && mv.IsNil() {
+			continue
+		}
+		w, err := wrapValue(mv, opts)
+		if err != nil {
+			return object{}, err
+		}
+		key, err := mapKeyString(iter.Key())
+		if err != nil {
+			return object{}, err
+		}
+		o.members = append(o.members, member{name: key, value: w})
+	}
+	sort.Slice(o.members, func(i, j int) bool {
+		return o.members[i].name < o.members[j].name
+	})
+	return o, nil
+}
@@ -0,0 +1,74 @@
+package jsonr
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// implementations maps interface types to the types that are allowed to be unmarshalled into them
+type implementations map[reflect.Type][]reflect.Type
+
+// RegisterInterface registers the interface type I, so that values like []Shape or map[string]Shape can be
+// unmarshalled. The implementations are registered like RegisterType, and are the only types, or pointers to them,
+// that are accepted for I. Without implementations any registered type implementing I is accepted.
+//
+// Example usage:
+//
+//	data, _ := jsonr.Marshal([]Shape{Circle{Radius: 1}, &Square{Side: 2}})
+//	result, _ := jsonr.Unmarshal(data, jsonr.RegisterInterface[Shape](Circle{}, &Square{}))
+//	// result will be []Shape{Circle{Radius: 1}, &Square{Side: 2}}
+func RegisterInterface[I any](impls ...any) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if opts.typeRegistry == nil {
+			opts.typeRegistry = make(typeRegistry)
+		}
+		if opts.implementations == nil {
+			opts.implementations = make(implementations)
+		}
+		return registerInterface(opts.typeRegistry, opts.implementations, reflect.TypeFor[I](), impls)
+	}
+}
+
+// registerInterface adds the interface and its implementations to the registries
+func registerInterface(types typeRegistry, impls implementations, iface reflect.Type, instances []any) error {
+	if iface == nil || iface.Kind() != reflect.Interface || iface.Name() == "" || iface.PkgPath() == "" {
+		return fmt.Errorf("%v is not a named interface", iface)
+	}
+
+	allowed := make([]reflect.Type, 0, len(instances))
+	for _, instance := range instances {
+		t := reflect.TypeOf(instance)
+		if t == nil || !t.Implements(iface) {
+			return fmt.Errorf("%v does not implement %s", t, iface)
+		}
+		// Pointers are resolved by their type name, only the type they point to is registered
+		rt := t
+		if rt.Kind() == reflect.Ptr {
+			rt = rt.Elem()
+		}
+		if err := types.registerType(rt); err != nil {
+			return err
+		}
+		allowed = append(allowed, t)
+	}
+
+	name, err := registeredName(iface)
+	if err != nil {
+		return err
+	}
+	if err := types.add(name, iface); err != nil {
+		return err
+	}
+	impls[iface] = append(append([]reflect.Type{}, impls[iface]...), allowed...)
+	return nil
+}
+
+// isImplementation reports if the type t is one of the allowed implementations, or a pointer to one of them
+func isImplementation(allowed []reflect.Type, t reflect.Type) bool {
+	for _, a := range allowed {
+		if t == a || t == reflect.PointerTo(a) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package jsonr
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+// Test interface with implementations using value and pointer receivers
+type TestShape interface {
+	Area() float64
+}
+
+type TestCircle struct {
+	Radius float64 `json:"radius"`
+}
+
+func (c TestCircle) Area() float64 {
+	return 3 * c.Radius * c.Radius
+}
+
+type TestSquare struct {
+	Side float64 `json:"side"`
+}
+
+func (s *TestSquare) Area() float64 {
+	return s.Side * s.Side
+}
+
+// Test struct with interface fields
+type TestDrawing struct {
+	Main   TestShape            `json:"main"`
+	Shapes map[string]TestShape `json:"shapes"`
+}
+
+// Test generic interface
+type TestSource[T any] interface {
+	Next() T
+}
+
+func TestRegisterInterface(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{
+			name: "Slice",
+			v:    []TestShape{TestCircle{Radius: 1}, &TestSquare{Side: 2}, nil},
+			want: `{"_t":"[]github.com/trojanc/jsonr.TestShape","v":[{"_t":"github.com/trojanc/jsonr.TestCircle","v":{"radius":1}},{"_t":"*github.com/trojanc/jsonr.TestSquare","v":{"side":2}},null]}`,
+		},
+		{
+			name: "Map",
+			v:    map[string]TestShape{"a": TestCircle{Radius: 1}},
+			want: `{"_t":"map[string]github.com/trojanc/jsonr.TestShape","v":{"a":{"_t":"github.com/trojanc/jsonr.TestCircle","v":{"radius":1}}}}`,
+		},
+		{
+			name: "Struct fields",
+			v:    TestDrawing{Main: &TestSquare{Side: 1}, Shapes: map[string]TestShape{"b": TestCircle{}}},
+			want: `{"_t":"github.com/trojanc/jsonr.TestDrawing","v":{"main":{"_t":"*github.com/trojanc/jsonr.TestSquare","v":{"side":1}},"shapes":{"b":{"_t":"github.com/trojanc/jsonr.TestCircle","v":{"radius":0}}}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.v)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			obj, err := Unmarshal(data, RegisterInterface[TestShape](TestCircle{}, &TestSquare{}), RegisterType(TestDrawing{}))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.v, obj)
+
+			registry := NewRegistry()
+			assert.NoError(t, registry.RegisterInterface(reflect.TypeFor[TestShape](), TestCircle{}, &TestSquare{}))
+			assert.NoError(t, registry.Register(TestDrawing{}))
+			obj, err = registry.Unmarshal(data)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.v, obj)
+		})
+	}
+}
+
+func TestRegisterInterfaceTypeChecking(t *testing.T) {
+	data, err := Marshal([]TestShape{TestCircle{Radius: 1}})
+	assert.NoError(t, err)
+
+	// Implementations that are not registered for the interface are rejected
+	_, err = Unmarshal(data, RegisterInterface[TestShape](&TestSquare{}), RegisterType(TestCircle{}))
+	assert.EqualError(t, err, "type jsonr.TestCircle is not an allowed implementation of jsonr.TestShape at $.v[0]")
+
+	// Without implementations any registered type implementing the interface is accepted
+	obj, err := Unmarshal(data, RegisterInterface[TestShape](), RegisterType(TestCircle{}))
+	assert.NoError(t, err)
+	assert.Equal(t, []TestShape{TestCircle{Radius: 1}}, obj)
+
+	// Types that do not implement the interface are rejected
+	data = []byte(`{"_t":"[]github.com/trojanc/jsonr.TestShape","v":[{"_t":"int","v":1}]}`)
+	_, err = Unmarshal(data, RegisterInterface[TestShape]())
+	assert.EqualError(t, err, "type int is not assignable to jsonr.TestShape at $.v[0]")
+
+	// Typed unmarshalling checks the implementations
+	data, err = Marshal(TestCircle{Radius: 1})
+	assert.NoError(t, err)
+	shape, err := UnmarshalAs[TestShape](data, RegisterInterface[TestShape](TestCircle{}))
+	assert.NoError(t, err)
+	assert.Equal(t, TestCircle{Radius: 1}, shape)
+
+	_, err = UnmarshalAs[TestShape](data, RegisterInterface[TestShape](&TestSquare{}), RegisterType(TestCircle{}))
+	assert.EqualError(t, err, "type \"github.com/trojanc/jsonr.TestCircle\" can not be unmarshalled as jsonr.TestShape")
+
+	err = UnmarshalInto(data, &shape, RegisterInterface[TestShape](&TestSquare{}), RegisterType(TestCircle{}))
+	assert.EqualError(t, err, "type \"github.com/trojanc/jsonr.TestCircle\" can not be unmarshalled as jsonr.TestShape")
+}
+
+func TestRegisterInterfaceErrors(t *testing.T) {
+	_, err := Unmarshal(nil, RegisterInterface[TestCircle]())
+	assert.EqualError(t, err, "could not apply option: jsonr.TestCircle is not a named interface")
+
+	_, err = Unmarshal(nil, RegisterInterface[any]())
+	assert.EqualError(t, err, "could not apply option: interface {} is not a named interface")
+
+	_, err = Unmarshal(nil, RegisterInterface[TestShape](TestSquare{}))
+	assert.EqualError(t, err, "could not apply option: jsonr.TestSquare does not implement jsonr.TestShape")
+
+	_, err = Unmarshal(nil, RegisterInterface[TestShape](nil))
+	assert.EqualError(t, err, "could not apply option: <nil> does not implement jsonr.TestShape")
+
+	// Generic interfaces should have a valid type name, like other types
+	_, err = Unmarshal(nil, RegisterInterface[TestSource[struct{ A int }]]())
+	assert.EqualError(t, err, "could not apply option: invalid type name \"github.com/trojanc/jsonr.TestSource[struct { A int }]\"")
+	_, err = Unmarshal([]byte(`{"_t":"github.com/trojanc/jsonr.TestSource[int]","v":null}`), RegisterInterface[TestSource[int]]())
+	assert.NoError(t, err)
+
+	// A failing registration leaves the registry untouched
+	registry := NewRegistry()
+	err = registry.RegisterInterface(reflect.TypeFor[TestShape](), TestCircle{}, TestSquare{})
+	assert.EqualError(t, err, "jsonr.TestSquare does not implement jsonr.TestShape")
+	_, exists := registry.Lookup("github.com/trojanc/jsonr.TestCircle")
+	assert.False(t, exists)
+	_, exists = registry.Lookup("github.com/trojanc/jsonr.TestShape")
+	assert.False(t, exists)
+}
@@ -0,0 +1,282 @@
+package jsonr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// limits the limits of the data that is unmarshalled, a zero limit is not checked
+type limits struct {
+	// depth the maximum nesting of JSON objects and arrays
+	depth int
+	// bytes the maximum size of the data
+	bytes int
+	// elements the maximum number of elements of a single JSON array, or members of a single JSON object
+	elements int
+	// stringLength the maximum length in bytes of a JSON string, including object keys
+	stringLength int
+	// typeDepth the maximum nesting of type names
+	typeDepth int
+}
+
+// WithMaxDepth limits the nesting of JSON objects and arrays, including the objects of the type envelopes. Every
+// value in an interface position adds a level for its envelope, like {"_t":"[]interface","v":[{"_t":"int","v":1}]}
+// having a depth of 3.
+func WithMaxDepth(n int) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if n <= 0 {
+			return errors.New("max depth should be positive")
+		}
+		opts.limits.depth = n
+		return nil
+	}
+}
+
+// WithMaxBytes limits the size of the data. A Decoder applies the limit to every value in the stream, and stops
+// reading the stream when a value is too large.
+func WithMaxBytes(n int) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if n <= 0 {
+			return errors.New("max bytes should be positive")
+		}
+		opts.limits.bytes = n
+		return nil
+	}
+}
+
+// WithMaxElements limits the number of elements of a single JSON array, and the number of members of a single JSON
+// object. This bounds the size of the slices and maps that are created while unmarshalling. The type envelopes are
+// objects with two members, so the limit should be at least 2. The limit also applies to the length of arrays and
+// the number of fields of anonymous structs in type names, like [1000]int, as these are allocated up front.
+func WithMaxElements(n int) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if n <= 0 {
+			return errors.New("max elements should be positive")
+		}
+		opts.limits.elements = n
+		return nil
+	}
+}
+
+// WithMaxStringLength limits the length in bytes of JSON strings, including object keys and type names
+func WithMaxStringLength(n int) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if n <= 0 {
+			return errors.New("max string length should be positive")
+		}
+		opts.limits.stringLength = n
+		return nil
+	}
+}
+
+// WithMaxTypeDepth limits the nesting of type names, where every pointer, slice, array, map, anonymous struct and
+// type argument adds a level, like map[string][]*int having a depth of 4. Type names are always limited to a depth
+// of 100.
+func WithMaxTypeDepth(n int) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if n <= 0 {
+			return errors.New("max type depth should be positive")
+		}
+		opts.limits.typeDepth = n
+		return nil
+	}
+}
+
+// limitFrame a JSON object or array that is being checked by checkLimits
+type limitFrame struct {
+	// array if the frame is an array, otherwise an object
+	array bool
+	// count the number of elements or members read so far
+	count int
+	// key the key of the last member of an object
+	key string
+	// wantKey if the next token of an object is a key
+	wantKey bool
+}
+
+// checkLimits verifies that the data does not exceed the limits of the unmarshal options. The data is scanned
+// token by token without recursion, so that it can be rejected before it is decoded. Syntax errors are left to be
+// reported by the decoding itself.
+func checkLimits(data []byte, opts *unmarshalOptions) error {
+	l := opts.limits
+	if l == (limits{}) {
+		return nil
+	}
+	if l.bytes > 0 && len(data) > l.bytes {
+		return &LimitError{Limit: "bytes", Max: l.bytes, Path: "$"}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var stack []*limitFrame
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+
+		var top *limitFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		if top != nil {
+			// Count the member or element starting with this token
+			if top.array || top.wantKey {
+				top.count++
+				if l.elements > 0 && top.count > l.elements {
+					return &LimitError{Limit: "elements", Max: l.elements, Path: limitPath(stack[:len(stack)-1])}
+				}
+			}
+			if top.wantKey {
+				top.key, top.wantKey = tok.(string), false
+				if l.stringLength > 0 && len(top.key) > l.stringLength {
+					return &LimitError{Limit: "string length", Max: l.stringLength, Path: limitPath(stack)}
+				}
+				continue
+			}
+			if !top.array {
+				top.wantKey = true
+			}
+		}
+
+		switch tok := tok.(type) {
+		case json.Delim:
+			if l.depth > 0 && len(stack) >= l.depth {
+				return &LimitError{Limit: "depth", Max: l.depth, Path: limitPath(stack)}
+			}
+			stack = append(stack, &limitFrame{array: tok == '[', wantKey: tok == '{'})
+		case string:
+			if l.stringLength > 0 && len(tok) > l.stringLength {
+				return &LimitError{Limit: "string length", Max: l.stringLength, Path: limitPath(stack)}
+			}
+			if top != nil && !top.array && top.key == opts.typeKey {
+				if err := checkTypeLimits(tok, opts); err != nil {
+					err.Path = limitPath(stack)
+					return err
+				}
+			}
+		}
+	}
+}
+
+// maxLimitedTypeSize the maximum size in bytes of the arrays and anonymous structs in type names when limits are
+// set. These are allocated in full even when the JSON holds null or [], so without a maximum a small input like a
+// [][1048576]int8 holding nulls would allocate a lot more memory than its size.
+const maxLimitedTypeSize = 256
+
+// checkTypeLimits verifies that the type name does not exceed the limits, as the type name decides what is allocated
+// before the value is read. Type names that can not be parsed or resolved are left to be reported by the decoding
+// itself.
+func checkTypeLimits(name string, opts *unmarshalOptions) *LimitError {
+	e, err := parseTypeName(name)
+	if err != nil {
+		return nil
+	}
+	if err := checkTypeExprLimits(e, opts.limits, 1); err != nil {
+		return err
+	}
+	if t, err := resolveTypeExpr(e, opts); t != nil && err == nil && !fitsTypeSize(t) {
+		return &LimitError{Limit: "type size", Max: maxLimitedTypeSize}
+	}
+	return nil
+}
+
+// fitsTypeSize reports if the arrays and anonymous structs of the type are at most maxLimitedTypeSize bytes. Named
+// types are not checked, as these are registered by the application.
+func fitsTypeSize(t reflect.Type) bool {
+	if t.Name() != "" {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		return fitsTypeSize(t.Elem())
+	case reflect.Map:
+		return fitsTypeSize(t.Key()) && fitsTypeSize(t.Elem())
+	case reflect.Array:
+		return t.Size() <= maxLimitedTypeSize && fitsTypeSize(t.Elem())
+	case reflect.Struct:
+		if t.Size() > maxLimitedTypeSize {
+			return false
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if !fitsTypeSize(t.Field(i).Type) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkTypeExprLimits verifies the expression at the given depth of a type name, and the expressions it contains
+func checkTypeExprLimits(e *typeExpr, l limits, depth int) *LimitError {
+	if e == nil {
+		return nil
+	}
+	if l.typeDepth > 0 && depth > l.typeDepth {
+		return &LimitError{Limit: "type depth", Max: l.typeDepth}
+	}
+	if l.elements > 0 && ((e.kind == arrayExpr && e.length > l.elements) || len(e.fields) > l.elements) {
+		return &LimitError{Limit: "elements", Max: l.elements}
+	}
+
+	children := append([]*typeExpr{e.key, e.elem}, e.args...)
+	for _, f := range e.fields {
+		children = append(children, f.typ)
+	}
+	for _, child := range children {
+		if err := checkTypeExprLimits(child, l, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// limitPath returns the JSON path of the current value of the frames
+func limitPath(stack []*limitFrame) string {
+	path := "$"
+	for _, f := range stack {
+		if f.array {
+			path += fmt.Sprintf("[%d]", f.count-1)
+		} else {
+			path += "." + f.key
+		}
+	}
+	return path
+}
+
+// limitReader stops reading from a stream once more than max bytes were read since the start of the current value,
+// so that a Decoder does not buffer a value exceeding WithMaxBytes
+type limitReader struct {
+	r io.Reader
+	// read the number of bytes read from r
+	read int64
+	// limit the number of bytes that can be read from r
+	limit int64
+	max   int
+}
+
+func (lr *limitReader) Read(p []byte) (int, error) {
+	if lr.read >= lr.limit {
+		return 0, &LimitError{Limit: "bytes", Max: lr.max, Path: "$"}
+	}
+	if int64(len(p)) > lr.limit-lr.read {
+		p = p[:lr.limit-lr.read]
+	}
+	n, err := lr.r.Read(p)
+	lr.read += int64(n)
+	return n, err
+}
+
+// reset allows reading max bytes from the offset of the next value
+func (lr *limitReader) reset(offset int64) {
+	lr.limit = offset + int64(lr.max) + 1
+}
@@ -0,0 +1,204 @@
+package jsonr
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		options []UnmarshalOption
+		want    *LimitError
+	}{
+		{
+			name:    "Depth within limit",
+			data:    `{"_t":"[]interface","v":[{"_t":"int","v":1}]}`,
+			options: []UnmarshalOption{WithMaxDepth(3)},
+		},
+		{
+			name:    "Depth",
+			data:    `{"_t":"[]interface","v":[{"_t":"int","v":1}]}`,
+			options: []UnmarshalOption{WithMaxDepth(2)},
+			want:    &LimitError{Limit: "depth", Max: 2, Path: "$.v[0]"},
+		},
+		{
+			name:    "Deeply nested envelopes",
+			data:    strings.Repeat(`{"_t":"[]interface","v":[`, 100000),
+			options: []UnmarshalOption{WithMaxDepth(64)},
+			want:    &LimitError{Limit: "depth", Max: 64, Path: "$" + strings.Repeat(".v[0]", 32)},
+		},
+		{
+			name:    "Bytes",
+			data:    `{"_t":"string","v":"test"}`,
+			options: []UnmarshalOption{WithMaxBytes(25)},
+			want:    &LimitError{Limit: "bytes", Max: 25, Path: "$"},
+		},
+		{
+			name:    "Elements within limit",
+			data:    `{"_t":"[]int","v":[1,2,3]}`,
+			options: []UnmarshalOption{WithMaxElements(3)},
+		},
+		{
+			name:    "Elements of an array",
+			data:    `{"_t":"[]int","v":[1,2,3]}`,
+			options: []UnmarshalOption{WithMaxElements(2)},
+			want:    &LimitError{Limit: "elements", Max: 2, Path: "$.v"},
+		},
+		{
+			name:    "Members of an object",
+			data:    `{"_t":"map[string]int","v":{"a":1,"b":2,"c":3}}`,
+			options: []UnmarshalOption{WithMaxElements(2)},
+			want:    &LimitError{Limit: "elements", Max: 2, Path: "$.v"},
+		},
+		{
+			name:    "String value",
+			data:    `{"_t":"[]string","v":["a","abcdefghi"]}`,
+			options: []UnmarshalOption{WithMaxStringLength(8)},
+			want:    &LimitError{Limit: "string length", Max: 8, Path: "$.v[1]"},
+		},
+		{
+			name:    "Object key",
+			data:    `{"_t":"map[string]int","v":{"a":1,"abcdefghijklmno":2}}`,
+			options: []UnmarshalOption{WithMaxStringLength(14)},
+			want:    &LimitError{Limit: "string length", Max: 14, Path: "$.v.abcdefghijklmno"},
+		},
+		{
+			name: "Array length of a type name",
+			data: `{"_t":"[999999999999]int8","v":[]}`,
+			options: []UnmarshalOption{WithMaxDepth(10), WithMaxElements(10), WithMaxBytes(100),
+				WithMaxStringLength(100)},
+			want: &LimitError{Limit: "elements", Max: 10, Path: "$._t"},
+		},
+		{
+			name:    "Nested array length of a type name",
+			data:    `{"_t":"[]interface","v":[{"_t":"map[string][2][11]int","v":{}}]}`,
+			options: []UnmarshalOption{WithMaxElements(10)},
+			want:    &LimitError{Limit: "elements", Max: 10, Path: "$.v[0]._t"},
+		},
+		{
+			name:    "Fields of an anonymous struct",
+			data:    `{"_t":"*struct{A int;B int;C int}","v":null}`,
+			options: []UnmarshalOption{WithMaxElements(2)},
+			want:    &LimitError{Limit: "elements", Max: 2, Path: "$._t"},
+		},
+		{
+			name:    "Array size of a type name",
+			data:    `{"_t":"[][1048576]int8","v":[` + strings.Repeat("null,", 1999) + `null]}`,
+			options: []UnmarshalOption{WithMaxBytes(1 << 20), WithMaxDepth(10), WithArrays(true)},
+			want:    &LimitError{Limit: "type size", Max: 256, Path: "$._t"},
+		},
+		{
+			name:    "Array size of a map value",
+			data:    `{"_t":"map[string]*[1024]int8","v":{}}`,
+			options: []UnmarshalOption{WithMaxElements(2000), WithArrays(true)},
+			want:    &LimitError{Limit: "type size", Max: 256, Path: "$._t"},
+		},
+		{
+			name:    "Array size within limit",
+			data:    `{"_t":"[][32]uint8","v":[null,null]}`,
+			options: []UnmarshalOption{WithMaxBytes(100), WithArrays(true)},
+		},
+		{
+			name: "Anonymous struct size",
+			data: `{"_t":"[]interface","v":[{"_t":"[]struct{A [100]int}","v":[]}]}`,
+			options: []UnmarshalOption{WithMaxDepth(10), WithAnonymousStructs(true),
+				WithArrays(true)},
+			want: &LimitError{Limit: "type size", Max: 256, Path: "$.v[0]._t"},
+		},
+		{
+			name:    "Type depth within limit",
+			data:    `{"_t":"map[string][]*int","v":{}}`,
+			options: []UnmarshalOption{WithMaxTypeDepth(4)},
+		},
+		{
+			name:    "Type depth",
+			data:    `{"_t":"map[string][]**int","v":{}}`,
+			options: []UnmarshalOption{WithMaxTypeDepth(4)},
+			want:    &LimitError{Limit: "type depth", Max: 4, Path: "$._t"},
+		},
+		{
+			name:    "Type name",
+			data:    `{"_t":"map[string]int","v":{}}`,
+			options: []UnmarshalOption{WithMaxStringLength(8)},
+			want:    &LimitError{Limit: "string length", Max: 8, Path: "$._t"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Unmarshal([]byte(tt.data), tt.options...)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var limitErr *LimitError
+			assert.ErrorAs(t, err, &limitErr)
+			assert.Equal(t, tt.want, limitErr)
+
+			_, err = UnmarshalAs[any]([]byte(tt.data), tt.options...)
+			assert.Equal(t, tt.want, err)
+
+			var v any
+			err = UnmarshalInto([]byte(tt.data), &v, tt.options...)
+			assert.Equal(t, tt.want, err)
+		})
+	}
+}
+
+func TestLimitsErrors(t *testing.T) {
+	tests := []struct {
+		option UnmarshalOption
+		err    string
+	}{
+		{option: WithMaxDepth(0), err: "could not apply option: max depth should be positive"},
+		{option: WithMaxBytes(-1), err: "could not apply option: max bytes should be positive"},
+		{option: WithMaxElements(0), err: "could not apply option: max elements should be positive"},
+		{option: WithMaxStringLength(0), err: "could not apply option: max string length should be positive"},
+		{option: WithMaxTypeDepth(0), err: "could not apply option: max type depth should be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			_, err := Unmarshal([]byte(`{"_t":"int","v":1}`), tt.option)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+
+	// The error message names the limit and the path
+	_, err := Unmarshal([]byte(`{"_t":"[]int","v":[1,2,3]}`), WithMaxElements(2))
+	assert.EqualError(t, err, "max elements of 2 exceeded at $.v")
+}
+
+// endlessReader reads the same byte forever
+type endlessReader byte
+
+func (r endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+func TestDecoderLimits(t *testing.T) {
+	stream := `{"_t":"string","v":"short"}` + "\n" + `{"_t":"string","v":"too long"}` + "\n"
+	dec := NewDecoder(strings.NewReader(stream), WithMaxBytes(28))
+	v, err := dec.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, "short", v)
+	_, err = dec.Decode()
+	assert.Equal(t, &LimitError{Limit: "bytes", Max: 28, Path: "$"}, err)
+
+	// A value that never ends is not read beyond the limit
+	dec = NewDecoder(io.MultiReader(strings.NewReader(`{"_t":"string","v":"`), endlessReader('a')), WithMaxBytes(1<<20))
+	_, err = dec.Decode()
+	assert.Equal(t, &LimitError{Limit: "bytes", Max: 1 << 20, Path: "$"}, err)
+
+	// Other limits apply to every value
+	dec = NewDecoder(strings.NewReader(`{"_t":"[]int","v":[1,2]} {"_t":"[]int","v":[1,2,3]}`), WithMaxElements(2))
+	_, err = dec.Decode()
+	assert.NoError(t, err)
+	_, err = dec.Decode()
+	assert.Equal(t, &LimitError{Limit: "elements", Max: 2, Path: "$.v"}, err)
+}
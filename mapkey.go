@@ -0,0 +1,157 @@
+package jsonr
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// mapEntry a single key/value pair of a map whose keys can not be represented as JSON object keys.
+// Maps with such keys are marshalled as an array of entries using the entry key and value key: [{"k":...,"v":...}]
+type mapEntry struct {
+	key   json.RawMessage
+	value any
+}
+
+// isTextualKey reports if map keys of the type can be used as JSON object keys
+func isTextualKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Bool:
+		return true
+	default:
+		return t.Implements(textMarshalerType)
+	}
+}
+
+// isJSONKey reports if encoding/json is able to marshal map keys of the type by itself
+func isJSONKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Bool:
+		return t.Implements(textMarshalerType)
+	default:
+		return isTextualKey(t)
+	}
+}
+
+// mapKeyString converts a textual map key into a JSON object key
+func mapKeyString(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", nil
+		}
+		b, err := tm.MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("could not marshal map key: %s", err.Error())
+		}
+		return string(b), nil
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(k.Float(), 'g', -1, k.Type().Bits()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(k.Bool()), nil
+	default:
+		return "", fmt.Errorf("unsupported map key %s", k.Type())
+	}
+}
+
+// parseMapKey converts a JSON object key back into a map key of type t
+func parseMapKey(key string, t reflect.Type) (reflect.Value, error) {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		kv := reflect.New(t)
+		if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+			return reflect.Value{}, fmt.Errorf("could not unmarshal map key %q: %s", key, err.Error())
+		}
+		return kv.Elem(), nil
+	}
+
+	kv := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		kv.SetString(key)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid map key %q for %s", key, t)
+		}
+		kv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(key, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid map key %q for %s", key, t)
+		}
+		kv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(key, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid map key %q for %s", key, t)
+		}
+		kv.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(key)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid map key %q for %s", key, t)
+		}
+		kv.SetBool(b)
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported map key %s", t)
+	}
+	return kv, nil
+}
+
+// wrapMapEntries rebuilds a map with non-textual keys as a list of entries, sorted by their marshalled keys
+// to produce stable output
+func wrapMapEntries(v reflect.Value, opts *marshalOptions) ([]object, error) {
+	entries := make([]mapEntry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		mv := iter.Value()
+		if opts.omitNil && mv.Kind() == reflect.Interface && mv.IsNil() {
+			continue
+		}
+		k, err := wrapValue(iter.Key(), opts)
+		if err != nil {
+			return nil, err
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal map key: %s", err.Error())
+		}
+		w, err := wrapValue(mv, opts)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, mapEntry{key: key, value: w})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return string(entries[i].key) < string(entries[j].key)
+	})
+
+	objects := make([]object, len(entries))
+	for i, e := range entries {
+		objects[i] = object{
+			members:    []member{{name: opts.entryKey, value: e.key}, {name: opts.valueKey, value: e.value}},
+			escapeHTML: opts.escapeHTML,
+		}
+	}
+	return objects, nil
+}
@@ -1,6 +1,7 @@
 package jsonr
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -10,6 +11,32 @@ import (
 type Wrapped struct {
 	Type  string `json:"_t"`
 	Value any    `json:"v"`
+
+	// opts the options used to marshal the wrapper
+	opts *marshalOptions
+	// inline if the Value is an object that holds the type next to its members
+	inline bool
+}
+
+// MarshalJSON marshals the type and value using the field names of the marshal options
+func (w Wrapped) MarshalJSON() ([]byte, error) {
+	opts := w.opts
+	if opts == nil {
+		opts = defaultMarshalOptions
+	}
+	if o, ok := w.Value.(object); ok && w.inline {
+		return object{
+			members:    append([]member{{name: opts.typeKey, value: w.Type}}, o.members...),
+			escapeHTML: opts.escapeHTML,
+		}.MarshalJSON()
+	}
+	return object{
+		members: []member{
+			{name: opts.typeKey, value: w.Type},
+			{name: opts.valueKey, value: w.Value},
+		},
+		escapeHTML: opts.escapeHTML,
+	}.MarshalJSON()
 }
 
 // Marshal encodes a Go value into JSON with type information. It wraps the value in a structure that includes
@@ -17,9 +44,12 @@ type Wrapped struct {
 //
 // The function handles:
 // - Primitive Go types
+// - Byte slices, as base64 or hex strings
+// - Complex numbers, as [real, imag]
+// - Named types, like `type Status string` or `type IDs []int`
 // - Structs and pointers to structs
 // - Maps with primitive keys and any value type
-// - Slices of any type
+// - Slices and fixed size arrays of any type
 // - Nested combinations of the above
 //
 // Example usage:
@@ -42,24 +72,70 @@ type Wrapped struct {
 //	data, _ := jsonr.Marshal(people)
 //
 // data will be {"_t":"map[string]github.com/project/example.Person","v":{"john":{"Name":"John","Age":30},"jane":{"Name":"Jane","Age":25}}}
-func Marshal(input any) ([]byte, error) {
-	w, err := Wrap(input)
+//
+// The output can be changed with MarshalOptions:
+//
+//	data, _ := jsonr.Marshal(person, jsonr.MarshalTypeKey("@type"), jsonr.MarshalValueKey("@value"))
+//	// data will be {"@type":"github.com/project/example.Person","@value":{"Name":"John","Age":30}}
+func Marshal(input any, options ...MarshalOption) ([]byte, error) {
+	opts, err := applyMarshalOptions(options...)
 	if err != nil {
 		return nil, err
 	}
-	data, err := json.Marshal(w)
+	return marshal(input, opts)
+}
+
+// marshal encodes a Go value into JSON with type information using the marshal options
+func marshal(input any, opts *marshalOptions) ([]byte, error) {
+	w, err := annotate(input, opts)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodeJSON(w, opts.escapeHTML)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal: %s", err.Error())
 	}
+	if opts.prefix != "" || opts.indent != "" {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, opts.prefix, opts.indent); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	}
 	return data, nil
 }
 
+// annotate adds the type information to the value, in minimal mode the value itself is not wrapped and only the
+// interface values it contains hold their type
+func annotate(input any, opts *marshalOptions) (any, error) {
+	opts = opts.walking()
+	if !opts.minimal {
+		return wrap(input, opts)
+	}
+	if input == nil {
+		return nil, nil
+	}
+	return wrapValue(reflect.ValueOf(input), opts)
+}
+
+// encodeJSON marshals the value like json.Marshal, optionally without escaping HTML characters
+func encodeJSON(v any, escapeHTML bool) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(escapeHTML)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
 // Wrap takes a Go value and wraps it in a structure that includes type information. This allows for proper
 // type reconstruction during unmarshalling. The function handles various Go types including primitives,
 // structs, maps, slices, and their nested combinations.
 //
-// For maps and slices containing interface{} values, it recursively wraps each element to preserve type
-// information throughout the entire data structure.
+// For maps, slices and structs containing interface{} values at any depth, it recursively wraps each interface
+// value to preserve type information throughout the entire data structure. Struct fields are walked using their
+// json tags, including embedded structs and the "omitempty" option.
 //
 // Example usage:
 //
@@ -81,79 +157,292 @@ func Marshal(input any) ([]byte, error) {
 //
 //	wrapped, _ := jsonr.Wrap(people)
 //	wrapped will contain type information and value
-func Wrap(input any) (*Wrapped, error) {
+func Wrap(input any, options ...MarshalOption) (*Wrapped, error) {
+	opts, err := applyMarshalOptions(options...)
+	if err != nil {
+		return nil, err
+	}
+	return wrap(input, opts.walking())
+}
+
+// wrap wraps a Go value in a structure that includes type information using the marshal options
+func wrap(input any, opts *marshalOptions) (*Wrapped, error) {
 	if input == nil {
 		return nil, nil
 	}
 
-	// Get reflection type and value
 	t := reflect.TypeOf(input)
-	v := reflect.ValueOf(input)
-
-	typeName := getTypeName(t)
-	// Dereference pointers
-	for t.Kind() == reflect.Ptr {
-		t = t.Elem()
-		v = v.Elem()
-	}
-
-	if t.Kind() == reflect.Map {
-
-		// Check the map key type
-		switch t.Key().Kind() {
-		case reflect.Ptr, reflect.Struct, reflect.Map, reflect.Slice:
-			return nil, fmt.Errorf("unsupported map key")
-		default:
-		}
-
-		// Check the map value type
-		switch t.Elem().Kind() {
-		case reflect.Interface:
-			// rebuild the map by wrapping each value
-			m := make(map[string]any)
-			for _, k := range v.MapKeys() {
-				w, err := Wrap(v.MapIndex(k).Interface())
-				if err != nil {
-					return nil, err
-				}
-				m[k.String()] = w
-			}
-			input = m
-		default:
-		}
-	} else if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
-		if t.Elem().Kind() == reflect.Interface {
-			// rebuild the slice
-			s := make([]any, 0)
-			for i := 0; i < v.Len(); i++ {
-				w, err := Wrap(v.Index(i).Interface())
-				if err != nil {
-					return nil, err
-				}
-				s = append(s, w)
-			}
-			input = s
+	e := typeExprOf(t, opts)
+	if !opts.anonymousStructs && e.hasStruct() {
+		return nil, &json.UnsupportedTypeError{Type: t}
+	}
+	typeName := e.String()
+
+	if opts.inline {
+		o, ok, err := inlineObject(reflect.ValueOf(input), opts)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &Wrapped{
+				Type:   typeName,
+				Value:  o,
+				opts:   opts,
+				inline: true,
+			}, nil
 		}
 	}
 
+	// Only walk the value when it contains values that require their own type information
+	if needsWrapping(t) {
+		value, err := wrapValue(reflect.ValueOf(input), opts)
+		if err != nil {
+			return nil, err
+		}
+		input = value
+	}
+
 	return &Wrapped{
 		Type:  typeName,
 		Value: input,
+		opts:  opts,
 	}, nil
 }
 
-// getTypeName returns a structured type name for deeply nested types
-func getTypeName(t reflect.Type) string {
-	switch t.Kind() {
+// wrapValue converts a value into a structure that can be marshalled by encoding/json, wrapping every interface
+// value found at any depth with its own type information.
+func wrapValue(v reflect.Value, opts *marshalOptions) (any, error) {
+	if !needsWrapping(v.Type()) || isOpaque(v.Type()) {
+		return leaf(v), nil
+	}
+	if opts.stdlib && v.Type() == urlType {
+		return marshalURL(v), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return wrap(v.Elem().Interface(), opts)
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if err := opts.cycles.enter(v); err != nil {
+			return nil, err
+		}
+		defer opts.cycles.leave(v)
+		return wrapValue(v.Elem(), opts)
 	case reflect.Slice, reflect.Array:
-		return "[]" + getTypeName(t.Elem())
+		if isByteSlice(v.Type()) {
+			return marshalBytes(v, opts.bytesEncoding), nil
+		}
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return nil, nil
+			}
+			if err := opts.cycles.enter(v); err != nil {
+				return nil, err
+			}
+			defer opts.cycles.leave(v)
+		}
+		// rebuild the slice by wrapping each value
+		s := make([]any, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			w, err := wrapValue(v.Index(i), opts)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = w
+		}
+		return s, nil
 	case reflect.Map:
-		return fmt.Sprintf("map[%s]%s", getTypeName(t.Key()), getTypeName(t.Elem()))
-	case reflect.Ptr:
-		return "*" + getTypeName(t.Elem())
+		if v.IsNil() {
+			return nil, nil
+		}
+		if err := opts.cycles.enter(v); err != nil {
+			return nil, err
+		}
+		defer opts.cycles.leave(v)
+		if !isTextualKey(v.Type().Key()) {
+			return wrapMapEntries(v, opts)
+		}
+		// rebuild the map by wrapping each value
+		m := make(map[string]any, v.Len())
+		for _, k := range v.MapKeys() {
+			mv := v.MapIndex(k)
+			if opts.omitNil && mv.Kind() == reflect.Interface && mv.IsNil() {
+				continue
+			}
+			w, err := wrapValue(mv, opts)
+			if err != nil {
+				return nil, err
+			}
+			key, err := mapKeyString(k)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = w
+		}
+		return m, nil
 	case reflect.Struct:
-		return t.PkgPath() + "." + t.Name()
+		return wrapStruct(v, opts)
+	case reflect.Complex64:
+		c := v.Complex()
+		return [2]float32{float32(real(c)), float32(imag(c))}, nil
+	case reflect.Complex128:
+		c := v.Complex()
+		return [2]float64{real(c), imag(c)}, nil
 	default:
-		return t.Kind().String()
+		return v.Interface(), nil
+	}
+}
+
+// startDetectingCycles the number of nested pointers, maps and slices after which they are checked for cycles,
+// like encoding/json this avoids the cost of tracking them for values that are not deeply nested
+const startDetectingCycles = 1000
+
+// cycleState tracks the pointers, maps and slices that are being walked, to report cycles instead of recursing
+// until the stack overflows
+type cycleState struct {
+	depth int
+	seen  map[cycleKey]struct{}
+}
+
+// cycleKey identifies a pointer, map or slice, slices also need their length as they can share their first element
+type cycleKey struct {
+	ptr uintptr
+	len int
+}
+
+// walking returns a copy of the options with a new cycle state, every call walking a value should use its own
+func (opts *marshalOptions) walking() *marshalOptions {
+	o := *opts
+	o.cycles = &cycleState{}
+	return &o
+}
+
+// enter marks a pointer, map or slice as being walked, returning an error when it is already being walked
+func (c *cycleState) enter(v reflect.Value) error {
+	c.depth++
+	if c.depth <= startDetectingCycles {
+		return nil
+	}
+	if c.seen == nil {
+		c.seen = make(map[cycleKey]struct{})
+	}
+	key := cycleKeyOf(v)
+	if _, ok := c.seen[key]; ok {
+		return &json.UnsupportedValueError{Value: v, Str: fmt.Sprintf("encountered a cycle via %s", v.Type())}
+	}
+	c.seen[key] = struct{}{}
+	return nil
+}
+
+// leave marks a pointer, map or slice as walked
+func (c *cycleState) leave(v reflect.Value) {
+	if c.depth > startDetectingCycles {
+		delete(c.seen, cycleKeyOf(v))
+	}
+	c.depth--
+}
+
+// cycleKeyOf returns the key identifying a pointer, map or slice
+func cycleKeyOf(v reflect.Value) cycleKey {
+	if v.Kind() == reflect.Slice {
+		return cycleKey{ptr: v.Pointer(), len: v.Len()}
+	}
+	return cycleKey{ptr: v.Pointer(), len: -1}
+}
+
+// leaf returns a value that is marshalled by encoding/json. Types that only implement json.Marshaler or
+// encoding.TextMarshaler with a pointer receiver are returned as pointer, so that encoding/json uses their methods.
+func leaf(v reflect.Value) any {
+	if !hasPointerMarshaler(v.Type()) {
+		return v.Interface()
+	}
+	if v.CanAddr() {
+		return v.Addr().Interface()
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p.Interface()
+}
+
+// wrapStruct rebuilds a struct as an ordered JSON object, honouring the json tags of its fields
+func wrapStruct(v reflect.Value, opts *marshalOptions) (object, error) {
+	fields := cachedFields(v.Type())
+	o := object{
+		members:    make([]member, 0, len(fields)),
+		escapeHTML: opts.escapeHTML,
+	}
+	for _, f := range fields {
+		fv := fieldByIndex(v, f.index, false)
+		if !fv.IsValid() || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		if opts.omitNil && fv.Kind() == reflect.Interface && fv.IsNil() {
+			continue
+		}
+		w, err := wrapValue(fv, opts)
+		if err != nil {
+			return object{}, err
+		}
+		if f.quoted {
+			if w, err = quote(w, opts); err != nil {
+				return object{}, err
+			}
+		}
+		o.members = append(o.members, member{name: f.name, value: w})
+	}
+	return o, nil
+}
+
+// quote encodes the value as a JSON string, as required by the ",string" tag option
+func quote(value any, opts *marshalOptions) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := encodeJSON(value, opts.escapeHTML)
+	if err != nil || string(data) == "null" {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// member a single member of an object
+type member struct {
+	name  string
+	value any
+}
+
+// object is a JSON object that keeps its members in order, used to marshal the fields of walked structs
+type object struct {
+	members    []member
+	escapeHTML bool
+}
+
+// MarshalJSON marshals the members of the object in order
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o.members {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := encodeJSON(m.name, o.escapeHTML)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := encodeJSON(m.value, o.escapeHTML)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
 	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
 }
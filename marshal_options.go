@@ -0,0 +1,212 @@
+package jsonr
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+)
+
+// marshalOptions Options that will be used while marshalling
+type marshalOptions struct {
+	// registry registry used to find the custom names of types
+	registry *Registry
+	// typeNames strategy used to name named types
+	typeNames TypeNameStrategy
+	// typeKey name of the field containing the type
+	typeKey string
+	// valueKey name of the field containing the value
+	valueKey string
+	// entryKey name of the field containing the key of map entries, see MarshalEntryKey
+	entryKey string
+	// prefix and indent used to indent the JSON
+	prefix, indent string
+	// escapeHTML if problematic HTML characters should be escaped
+	escapeHTML bool
+	// omitNil if struct fields and map entries with nil interface values should be omitted
+	omitNil bool
+	// inline if structs and maps should hold the type key next to their members instead of a value key
+	inline bool
+	// minimal if only interface values should hold their type, leaving the marshalled value itself unwrapped
+	minimal bool
+	// stdlib if standard library types should use stable names and encodings, see MarshalStdlibTypes
+	stdlib bool
+	// bytesEncoding how byte slices are written
+	bytesEncoding BytesEncoding
+	// anonymousStructs if anonymous structs can be marshalled, see MarshalAnonymousStructs
+	anonymousStructs bool
+	// cycles tracks the pointers, maps and slices walked by a single call, see walking
+	cycles *cycleState
+}
+
+// defaultMarshalOptions options used when marshalling without any options
+var defaultMarshalOptions = &marshalOptions{
+	registry:         primitiveRegistry,
+	typeNames:        FullTypeNames,
+	typeKey:          "_t",
+	valueKey:         "v",
+	entryKey:         "k",
+	escapeHTML:       true,
+	anonymousStructs: true,
+}
+
+// MarshalOption is a function that modifies the marshalOptions
+type MarshalOption func(*marshalOptions) error
+
+// TypeNameStrategy returns the name of a named type when marshalling. Names that are not valid type names are
+// ignored, and the full type name is used instead.
+type TypeNameStrategy func(t reflect.Type) string
+
+// FullTypeNames names types with their package path and name, e.g. github.com/project/example.Person. This is the
+// default strategy, and matches the names used by RegisterType. Instantiated generic types include their type
+// arguments, e.g. github.com/project/example.Page[github.com/project/example.Person].
+func FullTypeNames(t reflect.Type) string {
+	return t.PkgPath() + "." + genericName(t.Name())
+}
+
+// ShortTypeNames names types with their package name and name, e.g. example.Person. Types named this way should be
+// registered with RegisterTypeAs when unmarshalling.
+func ShortTypeNames(t reflect.Type) string {
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+// MarshalIndent indents the JSON like json.MarshalIndent, with every line starting with the prefix followed by one
+// or more copies of indent.
+func MarshalIndent(prefix, indent string) MarshalOption {
+	return func(opts *marshalOptions) error {
+		opts.prefix = prefix
+		opts.indent = indent
+		return nil
+	}
+}
+
+// MarshalEscapeHTML specifies if problematic HTML characters should be escaped inside JSON strings, the default
+// is true. See json.Encoder.SetEscapeHTML.
+func MarshalEscapeHTML(on bool) MarshalOption {
+	return func(opts *marshalOptions) error {
+		opts.escapeHTML = on
+		return nil
+	}
+}
+
+// MarshalTypeKey sets the name of the field containing the type, the default is "_t"
+func MarshalTypeKey(key string) MarshalOption {
+	return func(opts *marshalOptions) error {
+		if key == "" {
+			return errors.New("type key should not be empty")
+		}
+		opts.typeKey = key
+		return nil
+	}
+}
+
+// MarshalValueKey sets the name of the field containing the value, the default is "v". It is also used for the
+// values of map entries, see MarshalEntryKey.
+func MarshalValueKey(key string) MarshalOption {
+	return func(opts *marshalOptions) error {
+		if key == "" {
+			return errors.New("value key should not be empty")
+		}
+		opts.valueKey = key
+		return nil
+	}
+}
+
+// MarshalEntryKey sets the name of the field containing the key of map entries, the default is "k". Maps with keys
+// that can not be JSON object keys, like structs, are written as a list of entries holding the key and the value,
+// using the entry key and the value key: [{"k":...,"v":...}].
+func MarshalEntryKey(key string) MarshalOption {
+	return func(opts *marshalOptions) error {
+		if key == "" {
+			return errors.New("entry key should not be empty")
+		}
+		opts.entryKey = key
+		return nil
+	}
+}
+
+// MarshalTypeNames sets the strategy used to name named types, the default is FullTypeNames. Names that are not
+// valid type names, like names of instantiated generic types, fall back to FullTypeNames. Types registered
+// with a custom name in the Registry always use that name.
+func MarshalTypeNames(strategy TypeNameStrategy) MarshalOption {
+	return func(opts *marshalOptions) error {
+		if strategy == nil {
+			return errors.New("type name strategy should not be nil")
+		}
+		opts.typeNames = strategy
+		return nil
+	}
+}
+
+// MarshalOmitNil omits struct fields and map entries with a nil interface value, instead of writing them as null
+func MarshalOmitNil() MarshalOption {
+	return func(opts *marshalOptions) error {
+		opts.omitNil = true
+		return nil
+	}
+}
+
+// MarshalInline writes structs and maps with the type key as a sibling of their members, like
+// {"_t":"example.Person","Name":"John"}, instead of nesting them under the value key. Other values, and values with
+// a member named like the type or value key, keep the wrapped form.
+func MarshalInline() MarshalOption {
+	return func(opts *marshalOptions) error {
+		opts.inline = true
+		return nil
+	}
+}
+
+// MarshalMinimal only writes type information for values in positions typed as an interface, the marshalled value
+// itself is written without a type. The data can only be unmarshalled with WithRootType, or UnmarshalAs with
+// WithMinimal, which provide the type of the value instead.
+func MarshalMinimal() MarshalOption {
+	return func(opts *marshalOptions) error {
+		opts.minimal = true
+		return nil
+	}
+}
+
+// MarshalAnonymousStructs specifies if anonymous structs can be marshalled, the default is true. Anonymous structs
+// are named by their fields, like struct{Name string `json:"name"`}, so they can be unmarshalled without
+// registration. When disabled, marshalling a value with an anonymous struct type fails with a
+// *json.UnsupportedTypeError.
+func MarshalAnonymousStructs(on bool) MarshalOption {
+	return func(opts *marshalOptions) error {
+		opts.anonymousStructs = on
+		return nil
+	}
+}
+
+// MarshalRegistry uses the custom type names of the Registry, see Registry.RegisterAs
+func MarshalRegistry(registry *Registry) MarshalOption {
+	return func(opts *marshalOptions) error {
+		if registry == nil {
+			return errors.New("registry should not be nil")
+		}
+		opts.registry = registry
+		return nil
+	}
+}
+
+// applyMarshalOptions Applies the given options and returns the applied marshalOptions
+func applyMarshalOptions(options ...MarshalOption) (*marshalOptions, error) {
+	if len(options) == 0 {
+		return defaultMarshalOptions, nil
+	}
+
+	opts := *defaultMarshalOptions
+	for _, o := range options {
+		err := o(&opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not apply option: %s", err.Error())
+		}
+	}
+	if opts.typeKey == opts.valueKey {
+		return nil, fmt.Errorf("type key and value key should not be the same: %q", opts.typeKey)
+	}
+	if opts.entryKey == opts.valueKey {
+		return nil, fmt.Errorf("entry key and value key should not be the same: %q", opts.entryKey)
+	}
+
+	return &opts, nil
+}
@@ -1,10 +1,12 @@
 package jsonr
 
 import (
+	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -44,6 +46,147 @@ type TestStructPtrs struct {
 	Byte    *byte    `json:"byte,omitempty"`
 }
 
+// Test struct with nested any fields
+type TestEvent struct {
+	Name    string     `json:"name"`
+	Payload any        `json:"payload,omitempty"`
+	Items   []any      `json:"items,omitempty"`
+	Nested  *TestEvent `json:"nested,omitempty"`
+	Ignored any        `json:"-"`
+	TestEventMeta
+}
+
+// Test struct embedded in TestEvent
+type TestEventMeta struct {
+	Meta    any `json:"meta,omitempty"`
+	Version int `json:"version,string,omitempty"`
+}
+
+// Test struct used as a map key
+type TestKey struct {
+	Tenant string `json:"tenant"`
+	Region string `json:"region,omitempty"`
+}
+
+// Test struct used as a map key through encoding.TextMarshaler
+type TestTextKey struct {
+	Tenant string
+	Region string
+}
+
+func (k TestTextKey) MarshalText() ([]byte, error) {
+	return []byte(k.Tenant + "/" + k.Region), nil
+}
+
+func (k *TestTextKey) UnmarshalText(text []byte) error {
+	k.Tenant, k.Region, _ = strings.Cut(string(text), "/")
+	return nil
+}
+
+// Test struct marshalled as a string by its own JSON methods
+type TestVersion struct {
+	Major, Minor int
+}
+
+func (v TestVersion) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%d.%d", v.Major, v.Minor))
+}
+
+func (v *TestVersion) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	_, err := fmt.Sscanf(s, "%d.%d", &v.Major, &v.Minor)
+	return err
+}
+
+// Test struct with unexported fields, marshalled by text methods with a pointer receiver
+type TestAmount struct {
+	cents int
+}
+
+func (a *TestAmount) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d.%02d", a.cents/100, a.cents%100)), nil
+}
+
+func (a *TestAmount) UnmarshalText(text []byte) error {
+	var units, cents int
+	if _, err := fmt.Sscanf(string(text), "%d.%d", &units, &cents); err != nil {
+		return err
+	}
+	a.cents = units*100 + cents
+	return nil
+}
+
+// Test struct with a field marshalled by text methods with a pointer receiver
+type TestPrice struct {
+	Amount TestAmount `json:"amount"`
+}
+
+// Test array marshalled as a hex string
+type TestID [4]byte
+
+func (id TestID) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%x", id[:])), nil
+}
+
+func (id *TestID) UnmarshalText(text []byte) error {
+	_, err := hex.Decode(id[:], text)
+	return err
+}
+
+// Test struct with an interface field that is marshalled by its own JSON methods
+type TestRaw struct {
+	Value any
+}
+
+func (r TestRaw) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{"raw": r.Value})
+}
+
+func (r *TestRaw) UnmarshalJSON(data []byte) error {
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	r.Value = m["raw"]
+	return nil
+}
+
+// Test struct with maps using keys that are not strings
+type TestKeyed struct {
+	Quotas   map[TestKey]int   `json:"quotas,omitempty"`
+	Cells    map[[2]int]string `json:"cells,omitempty"`
+	Anything map[any]any       `json:"anything,omitempty"`
+	Labels   map[TestLabel]any `json:"labels,omitempty"`
+}
+
+// Test string type used as a map key
+type TestLabel string
+
+// Test struct with a field using the type key
+type TestTyped struct {
+	Type string `json:"_t"`
+}
+
+// Test named types that are not structs
+type (
+	TestStatus string
+	TestIDs    []int
+	TestLookup map[string]TestStatus
+	TestBag    map[string]any
+	TestBlob   []uint8
+	TestPhase  complex64
+)
+
+// Test struct with byte slice fields
+type TestFile struct {
+	Name string   `json:"name"`
+	Data []byte   `json:"data"`
+	Blob TestBlob `json:"blob,omitempty"`
+}
+
 func Test_MarshalAndUnmarshal(t *testing.T) {
 	type args struct {
 		v any
@@ -465,16 +608,6 @@ func Test_MarshalAndUnmarshal(t *testing.T) {
 			},
 			want: "{\"_t\":\"map[string]*github.com/trojanc/jsonr.TestStruct\",\"v\":{\"foo\":{\"string\":\"string1\"},\"john\":{\"int\":1}}}",
 		},
-		{
-			name: "Error map[*string]*TestStruct",
-			args: args{
-				v: map[*string]*TestStruct{
-					ptr("foo"):  {String: "string1"},
-					ptr("john"): {Int: 1},
-				},
-			},
-			wantErr: errors.New("unsupported map key"),
-		},
 		{
 			name: "map[string]any with TestStruct",
 			args: args{
@@ -496,15 +629,102 @@ func Test_MarshalAndUnmarshal(t *testing.T) {
 			want: "{\"_t\":\"map[string]interface\",\"v\":{\"foo\":{\"_t\":\"*github.com/trojanc/jsonr.TestStruct\",\"v\":{\"string\":\"string1\"}},\"john\":{\"_t\":\"*github.com/trojanc/jsonr.TestStruct\",\"v\":{\"int\":1}}}}",
 		},
 		{
-			name: "Error map[TestStruct]*TestStruct",
+			name: "map[TestStruct]*TestStruct",
 			args: args{
 				v: map[TestStruct]*TestStruct{
-					TestStruct{String: "a"}: {String: "string1"},
 					TestStruct{String: "b"}: {Int: 1},
+					TestStruct{String: "a"}: {String: "string1"},
+				},
+			},
+			want: "{\"_t\":\"map[github.com/trojanc/jsonr.TestStruct]*github.com/trojanc/jsonr.TestStruct\",\"v\":[{\"k\":{\"string\":\"a\"},\"v\":{\"string\":\"string1\"}},{\"k\":{\"string\":\"b\"},\"v\":{\"int\":1}}]}",
+		},
+		{
+			name: "map[TestKey]TestStruct",
+			args: args{
+				v: map[TestKey]TestStruct{
+					{Tenant: "a"}:               {Int: 1},
+					{Tenant: "b", Region: "eu"}: {String: "x"},
 				},
 			},
-			want:    nil,
-			wantErr: errors.New("unsupported map key"),
+			want: "{\"_t\":\"map[github.com/trojanc/jsonr.TestKey]github.com/trojanc/jsonr.TestStruct\",\"v\":[{\"k\":{\"tenant\":\"a\"},\"v\":{\"int\":1}},{\"k\":{\"tenant\":\"b\",\"region\":\"eu\"},\"v\":{\"string\":\"x\"}}]}",
+		},
+		{
+			name: "map[TestTextKey]int",
+			args: args{
+				v: map[TestTextKey]int{
+					{Tenant: "a", Region: "eu"}: 1,
+				},
+			},
+			want: "{\"_t\":\"map[github.com/trojanc/jsonr.TestTextKey]int\",\"v\":{\"a/eu\":1}}",
+		},
+		{
+			name: "map[TestTextKey]any",
+			args: args{
+				v: map[TestTextKey]any{
+					{Tenant: "a", Region: "eu"}: 1,
+				},
+			},
+			want: "{\"_t\":\"map[github.com/trojanc/jsonr.TestTextKey]interface\",\"v\":{\"a/eu\":{\"_t\":\"int\",\"v\":1}}}",
+		},
+		{
+			name: "map[int]any",
+			args: args{
+				v: map[int]any{
+					1:  "a",
+					-2: TestStruct{Int: 1},
+				},
+			},
+			want: "{\"_t\":\"map[int]interface\",\"v\":{\"-2\":{\"_t\":\"github.com/trojanc/jsonr.TestStruct\",\"v\":{\"int\":1}},\"1\":{\"_t\":\"string\",\"v\":\"a\"}}}",
+		},
+		{
+			name: "map[uint64]any",
+			args: args{
+				v: map[uint64]any{
+					18446744073709551615: true,
+				},
+			},
+			want: "{\"_t\":\"map[uint64]interface\",\"v\":{\"18446744073709551615\":{\"_t\":\"bool\",\"v\":true}}}",
+		},
+		{
+			name: "map[bool]any",
+			args: args{
+				v: map[bool]any{
+					true:  1,
+					false: nil,
+				},
+			},
+			want: "{\"_t\":\"map[bool]interface\",\"v\":{\"false\":null,\"true\":{\"_t\":\"int\",\"v\":1}}}",
+		},
+		{
+			name: "map[float32]string",
+			args: args{
+				v: map[float32]string{
+					1.1:   "a",
+					-0.25: "b",
+				},
+			},
+			want: "{\"_t\":\"map[float32]string\",\"v\":{\"-0.25\":\"b\",\"1.1\":\"a\"}}",
+		},
+		{
+			name: "map[int8]string",
+			args: args{
+				v: map[int8]string{
+					-1: "a",
+				},
+			},
+			want: "{\"_t\":\"map[int8]string\",\"v\":{\"-1\":\"a\"}}",
+		},
+		{
+			name: "TestKeyed with array and any keys",
+			args: args{
+				v: TestKeyed{
+					Quotas:   map[TestKey]int{{Tenant: "a"}: 10},
+					Cells:    map[[2]int]string{{1, 2}: "a"},
+					Anything: map[any]any{TestKey{Tenant: "a"}: 1},
+					Labels:   map[TestLabel]any{"env": "prod"},
+				},
+			},
+			want: "{\"_t\":\"github.com/trojanc/jsonr.TestKeyed\",\"v\":{\"quotas\":[{\"k\":{\"tenant\":\"a\"},\"v\":10}],\"cells\":[{\"k\":[1,2],\"v\":\"a\"}],\"anything\":[{\"k\":{\"_t\":\"github.com/trojanc/jsonr.TestKey\",\"v\":{\"tenant\":\"a\"}},\"v\":{\"_t\":\"int\",\"v\":1}}],\"labels\":{\"env\":{\"_t\":\"string\",\"v\":\"prod\"}}}}",
 		},
 		{
 			name: "map[string][]TestStruct",
@@ -516,17 +736,6 @@ func Test_MarshalAndUnmarshal(t *testing.T) {
 			},
 			want: "{\"_t\":\"map[string][]github.com/trojanc/jsonr.TestStruct\",\"v\":{\"a\":[{\"string\":\"string1\"}],\"b\":[{\"int\":1}]}}",
 		},
-		{ // Even though marshalling to pointers i
-			name: "map[string]map[*string]string",
-			args: args{
-				v: map[string]map[*string]string{
-					"key": {
-						ptr("key1"): "value",
-					},
-				},
-			},
-			wantErr: errors.New("failed to unmarshal: json: unsupported type: map[*string]string"),
-		},
 		{
 			name: "map[string]*[]TestStruct",
 			args: args{
@@ -567,6 +776,160 @@ func Test_MarshalAndUnmarshal(t *testing.T) {
 			},
 			want: "{\"_t\":\"map[string]interface\",\"v\":{\"1\":null,\"2\":null}}",
 		},
+		{
+			name: "TestEvent with any field",
+			args: args{
+				v: TestEvent{
+					Name:    "created",
+					Payload: TestStruct{String: "a"},
+				},
+			},
+			want: "{\"_t\":\"github.com/trojanc/jsonr.TestEvent\",\"v\":{\"name\":\"created\",\"payload\":{\"_t\":\"github.com/trojanc/jsonr.TestStruct\",\"v\":{\"string\":\"a\"}}}}",
+		},
+		{
+			name: "TestEvent with nil any field",
+			args: args{
+				v: TestEvent{
+					Name: "created",
+				},
+			},
+			want: "{\"_t\":\"github.com/trojanc/jsonr.TestEvent\",\"v\":{\"name\":\"created\"}}",
+		},
+		{
+			name: "*TestEvent with nested and embedded any fields",
+			args: args{
+				v: &TestEvent{
+					Name:  "updated",
+					Items: []any{1, "two", &TestStruct{Int: 3}},
+					Nested: &TestEvent{
+						Name:    "created",
+						Payload: map[string]any{"a": int8(1)},
+					},
+					TestEventMeta: TestEventMeta{
+						Meta:    TestStructPtrs{Bool: ptr(true)},
+						Version: 2,
+					},
+				},
+			},
+			want: "{\"_t\":\"*github.com/trojanc/jsonr.TestEvent\",\"v\":{\"name\":\"updated\",\"items\":[{\"_t\":\"int\",\"v\":1},{\"_t\":\"string\",\"v\":\"two\"},{\"_t\":\"*github.com/trojanc/jsonr.TestStruct\",\"v\":{\"int\":3}}],\"nested\":{\"name\":\"created\",\"payload\":{\"_t\":\"map[string]interface\",\"v\":{\"a\":{\"_t\":\"int8\",\"v\":1}}}},\"meta\":{\"_t\":\"github.com/trojanc/jsonr.TestStructPtrs\",\"v\":{\"bool\":true}},\"version\":\"2\"}}",
+		},
+		{
+			name: "map[string]any with TestEvent",
+			args: args{
+				v: map[string]any{
+					"event": TestEvent{Name: "created", Payload: 1.5},
+				},
+			},
+			want: "{\"_t\":\"map[string]interface\",\"v\":{\"event\":{\"_t\":\"github.com/trojanc/jsonr.TestEvent\",\"v\":{\"name\":\"created\",\"payload\":{\"_t\":\"float64\",\"v\":1.5}}}}}",
+		},
+		{
+			name: "[]TestEvent",
+			args: args{
+				v: []TestEvent{
+					{Name: "a", Payload: true},
+					{Name: "b", Payload: []any{nil}},
+				},
+			},
+			want: "{\"_t\":\"[]github.com/trojanc/jsonr.TestEvent\",\"v\":[{\"name\":\"a\",\"payload\":{\"_t\":\"bool\",\"v\":true}},{\"name\":\"b\",\"payload\":{\"_t\":\"[]interface\",\"v\":[null]}}]}",
+		},
+		{
+			name: "TestStatus",
+			args: args{
+				v: TestStatus("active"),
+			},
+			want: "{\"_t\":\"github.com/trojanc/jsonr.TestStatus\",\"v\":\"active\"}",
+		},
+		{
+			name: "*TestStatus",
+			args: args{
+				v: ptr(TestStatus("active")),
+			},
+			want: "{\"_t\":\"*github.com/trojanc/jsonr.TestStatus\",\"v\":\"active\"}",
+		},
+		{
+			name: "TestIDs",
+			args: args{
+				v: TestIDs{1, 2},
+			},
+			want: "{\"_t\":\"github.com/trojanc/jsonr.TestIDs\",\"v\":[1,2]}",
+		},
+		{
+			name: "TestLookup",
+			args: args{
+				v: TestLookup{"a": "active"},
+			},
+			want: "{\"_t\":\"github.com/trojanc/jsonr.TestLookup\",\"v\":{\"a\":\"active\"}}",
+		},
+		{
+			name: "TestBag",
+			args: args{
+				v: TestBag{"a": TestStatus("active")},
+			},
+			want: "{\"_t\":\"github.com/trojanc/jsonr.TestBag\",\"v\":{\"a\":{\"_t\":\"github.com/trojanc/jsonr.TestStatus\",\"v\":\"active\"}}}",
+		},
+		{
+			name: "[]any with named types",
+			args: args{
+				v: []any{TestStatus("active"), TestIDs{3}, []TestStatus{"a"}},
+			},
+			want: "{\"_t\":\"[]interface\",\"v\":[{\"_t\":\"github.com/trojanc/jsonr.TestStatus\",\"v\":\"active\"},{\"_t\":\"github.com/trojanc/jsonr.TestIDs\",\"v\":[3]},{\"_t\":\"[]github.com/trojanc/jsonr.TestStatus\",\"v\":[\"a\"]}]}",
+		},
+		{
+			name: "map[TestLabel]any",
+			args: args{
+				v: map[TestLabel]any{"env": "prod"},
+			},
+			want: "{\"_t\":\"map[github.com/trojanc/jsonr.TestLabel]interface\",\"v\":{\"env\":{\"_t\":\"string\",\"v\":\"prod\"}}}",
+		},
+		{
+			name: "[3]float64",
+			args: args{
+				v: [3]float64{1.5, 2, 3},
+			},
+			want: "{\"_t\":\"[3]float64\",\"v\":[1.5,2,3]}",
+		},
+		{
+			name: "[4]byte",
+			args: args{
+				v: [4]byte{1, 2, 3, 4},
+			},
+			want: "{\"_t\":\"[4]uint8\",\"v\":[1,2,3,4]}",
+		},
+		{
+			name: "*[2]TestStruct",
+			args: args{
+				v: &[2]TestStruct{{Int: 1}, {String: "a"}},
+			},
+			want: "{\"_t\":\"*[2]github.com/trojanc/jsonr.TestStruct\",\"v\":[{\"int\":1},{\"string\":\"a\"}]}",
+		},
+		{
+			name: "[2]any",
+			args: args{
+				v: [2]any{1, nil},
+			},
+			want: "{\"_t\":\"[2]interface\",\"v\":[{\"_t\":\"int\",\"v\":1},null]}",
+		},
+		{
+			name: "[][2]int",
+			args: args{
+				v: [][2]int{{1, 2}, {3, 4}},
+			},
+			want: "{\"_t\":\"[][2]int\",\"v\":[[1,2],[3,4]]}",
+		},
+		{
+			name: "map[[2]int]string",
+			args: args{
+				v: map[[2]int]string{{1, 2}: "a"},
+			},
+			want: "{\"_t\":\"map[[2]int]string\",\"v\":[{\"k\":[1,2],\"v\":\"a\"}]}",
+		},
+		{
+			name: "[]any with arrays",
+			args: args{
+				v: []any{[2]string{"a", "b"}, []string{"c"}},
+			},
+			want: "{\"_t\":\"[]interface\",\"v\":[{\"_t\":\"[2]string\",\"v\":[\"a\",\"b\"]},{\"_t\":\"[]string\",\"v\":[\"c\"]}]}",
+		},
 		{
 			name: "[]any with nils",
 			args: args{
@@ -593,6 +956,16 @@ func Test_MarshalAndUnmarshal(t *testing.T) {
 				obj, err := Unmarshal(got,
 					RegisterType(TestStruct{}),
 					RegisterType(TestStructPtrs{}),
+					RegisterType(TestEvent{}),
+					RegisterType(TestKey{}),
+					RegisterType(TestTextKey{}),
+					RegisterType(TestKeyed{}),
+					RegisterType(TestLabel("")),
+					RegisterType(TestStatus("")),
+					RegisterType(TestIDs{}),
+					RegisterType(TestLookup{}),
+					RegisterType(TestBag{}),
+					WithArrays(true),
 				)
 				assert.NoError(t, err)
 				fmt.Println(obj)
@@ -602,6 +975,41 @@ func Test_MarshalAndUnmarshal(t *testing.T) {
 	}
 }
 
+func Test_MarshalAndUnmarshalPointerKeys(t *testing.T) {
+	data, err := Marshal(map[*string]*TestStruct{
+		ptr("foo"):  {String: "string1"},
+		ptr("john"): {Int: 1},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"_t\":\"map[*string]*github.com/trojanc/jsonr.TestStruct\",\"v\":[{\"k\":\"foo\",\"v\":{\"string\":\"string1\"}},{\"k\":\"john\",\"v\":{\"int\":1}}]}", string(data))
+
+	obj, err := Unmarshal(data, RegisterType(TestStruct{}))
+	assert.NoError(t, err)
+	got := make(map[string]*TestStruct)
+	for k, v := range obj.(map[*string]*TestStruct) {
+		got[*k] = v
+	}
+	assert.Equal(t, map[string]*TestStruct{
+		"foo":  {String: "string1"},
+		"john": {Int: 1},
+	}, got)
+
+	data, err = Marshal(map[string]map[*string]string{
+		"key": {
+			ptr("key1"): "value",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"_t\":\"map[string]map[*string]string\",\"v\":{\"key\":[{\"k\":\"key1\",\"v\":\"value\"}]}}", string(data))
+
+	obj, err = Unmarshal(data)
+	assert.NoError(t, err)
+	for k, v := range obj.(map[string]map[*string]string)["key"] {
+		assert.Equal(t, "key1", *k)
+		assert.Equal(t, "value", v)
+	}
+}
+
 func TestCompareGoJSON(t *testing.T) {
 	obj := TestStruct{
 		String: "tester",
@@ -633,6 +1041,549 @@ func TestCompareGoJSON(t *testing.T) {
 	assert.Equal(t, obj, data)
 }
 
+func TestMarshalCycles(t *testing.T) {
+	payload := &TestEvent{Name: "payload"}
+	payload.Payload = payload
+	nested := &TestEvent{Name: "nested"}
+	nested.Nested = nested
+	m := map[string]any{}
+	m["self"] = m
+	s := []any{nil}
+	s[0] = s
+
+	tests := []struct {
+		name    string
+		input   any
+		options []MarshalOption
+		typ     string
+	}{
+		{"interface field", payload, nil, "*jsonr.TestEvent"},
+		{"pointer field", nested, nil, "*jsonr.TestEvent"},
+		{"map", m, nil, "map[string]interface {}"},
+		{"slice", s, nil, "[]interface {}"},
+		{"inline", payload, []MarshalOption{MarshalInline()}, "*jsonr.TestEvent"},
+		{"inline map", m, []MarshalOption{MarshalInline()}, "map[string]interface {}"},
+		{"minimal", nested, []MarshalOption{MarshalMinimal()}, "*jsonr.TestEvent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Marshal(tt.input, tt.options...)
+			var valueErr *json.UnsupportedValueError
+			if assert.ErrorAs(t, err, &valueErr) {
+				assert.Equal(t, "encountered a cycle via "+tt.typ, valueErr.Str)
+			}
+		})
+	}
+
+	// values that are deeply nested without a cycle are still marshalled
+	deep := &TestEvent{Name: "end"}
+	for i := 0; i < startDetectingCycles+10; i++ {
+		deep = &TestEvent{Nested: deep}
+	}
+	_, err := Marshal(deep)
+	assert.NoError(t, err)
+}
+
 func ptr[T any](v T) *T {
 	return &v
 }
+
+// Test struct with fields that can be nil
+type TestNillable struct {
+	Value any         `json:"value"`
+	Ptr   *TestStruct `json:"ptr"`
+}
+
+func TestMarshalOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       any
+		options []MarshalOption
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "Indent",
+			v:       []any{1},
+			options: []MarshalOption{MarshalIndent(">", "  ")},
+			want:    "{\n>  \"_t\": \"[]interface\",\n>  \"v\": [\n>    {\n>      \"_t\": \"int\",\n>      \"v\": 1\n>    }\n>  ]\n>}",
+		},
+		{
+			name:    "Escape HTML",
+			v:       TestEvent{Name: "<a>", Payload: "&"},
+			options: []MarshalOption{},
+			want:    "{\"_t\":\"github.com/trojanc/jsonr.TestEvent\",\"v\":{\"name\":\"\\u003ca\\u003e\",\"payload\":{\"_t\":\"string\",\"v\":\"\\u0026\"}}}",
+		},
+		{
+			name:    "Do not escape HTML",
+			v:       TestEvent{Name: "<a>", Payload: "&"},
+			options: []MarshalOption{MarshalEscapeHTML(false)},
+			want:    "{\"_t\":\"github.com/trojanc/jsonr.TestEvent\",\"v\":{\"name\":\"<a>\",\"payload\":{\"_t\":\"string\",\"v\":\"&\"}}}",
+		},
+		{
+			name:    "Type and value keys",
+			v:       map[string]any{"a": []any{TestStruct{Int: 1}}},
+			options: []MarshalOption{MarshalTypeKey("@type"), MarshalValueKey("@value")},
+			want:    "{\"@type\":\"map[string]interface\",\"@value\":{\"a\":{\"@type\":\"[]interface\",\"@value\":[{\"@type\":\"github.com/trojanc/jsonr.TestStruct\",\"@value\":{\"int\":1}}]}}}",
+		},
+		{
+			name:    "Short type names",
+			v:       map[TestStatus][]*TestStruct{"a": nil},
+			options: []MarshalOption{MarshalTypeNames(ShortTypeNames)},
+			want:    "{\"_t\":\"map[jsonr.TestStatus][]*jsonr.TestStruct\",\"v\":{\"a\":null}}",
+		},
+		{
+			name: "Invalid type names",
+			v:    TestStatus("a"),
+			options: []MarshalOption{MarshalTypeNames(func(t reflect.Type) string {
+				return "[" + t.Name() + "]"
+			})},
+			want: "{\"_t\":\"github.com/trojanc/jsonr.TestStatus\",\"v\":\"a\"}",
+		},
+		{
+			name:    "Omit nil",
+			v:       map[string]any{"a": nil, "b": TestNillable{}},
+			options: []MarshalOption{MarshalOmitNil()},
+			want:    "{\"_t\":\"map[string]interface\",\"v\":{\"b\":{\"_t\":\"github.com/trojanc/jsonr.TestNillable\",\"v\":{\"ptr\":null}}}}",
+		},
+		{
+			name:    "Omit nil map entries",
+			v:       map[TestKey]any{{Tenant: "a"}: nil, {Tenant: "b"}: 1},
+			options: []MarshalOption{MarshalOmitNil()},
+			want:    "{\"_t\":\"map[github.com/trojanc/jsonr.TestKey]interface\",\"v\":[{\"k\":{\"tenant\":\"b\"},\"v\":{\"_t\":\"int\",\"v\":1}}]}",
+		},
+		{
+			name:    "Without omit nil",
+			v:       map[string]any{"a": nil, "b": TestNillable{}},
+			options: []MarshalOption{},
+			want:    "{\"_t\":\"map[string]interface\",\"v\":{\"a\":null,\"b\":{\"_t\":\"github.com/trojanc/jsonr.TestNillable\",\"v\":{\"value\":null,\"ptr\":null}}}}",
+		},
+		{
+			name:    "Empty type key",
+			options: []MarshalOption{MarshalTypeKey("")},
+			wantErr: "could not apply option: type key should not be empty",
+		},
+		{
+			name:    "Empty value key",
+			options: []MarshalOption{MarshalValueKey("")},
+			wantErr: "could not apply option: value key should not be empty",
+		},
+		{
+			name:    "Same type and value key",
+			options: []MarshalOption{MarshalTypeKey("v")},
+			wantErr: "type key and value key should not be the same: \"v\"",
+		},
+		{
+			name:    "Empty entry key",
+			options: []MarshalOption{MarshalEntryKey("")},
+			wantErr: "could not apply option: entry key should not be empty",
+		},
+		{
+			name:    "Same entry and value key",
+			options: []MarshalOption{MarshalValueKey("k")},
+			wantErr: "entry key and value key should not be the same: \"k\"",
+		},
+		{
+			name:    "Nil type name strategy",
+			options: []MarshalOption{MarshalTypeNames(nil)},
+			wantErr: "could not apply option: type name strategy should not be nil",
+		},
+		{
+			name:    "Nil registry",
+			options: []MarshalOption{MarshalRegistry(nil)},
+			wantErr: "could not apply option: registry should not be nil",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.v, tt.options...)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestMarshalShortTypeNames(t *testing.T) {
+	data, err := Marshal([]any{TestStruct{Int: 1}}, MarshalTypeNames(ShortTypeNames))
+	assert.NoError(t, err)
+
+	obj, err := Unmarshal(data, RegisterTypeAs("jsonr.TestStruct", TestStruct{}))
+	assert.NoError(t, err)
+	assert.Equal(t, []any{TestStruct{Int: 1}}, obj)
+}
+
+func TestMarshalAndUnmarshalEnvelopeKeys(t *testing.T) {
+	input := map[string]any{
+		"event": TestEvent{Name: "a", Payload: []any{1, TestStruct{Int: 2}}, Items: []any{"b"}},
+		"keyed": map[any]any{TestKey{Tenant: "t"}: ptr[any](3)},
+	}
+	for _, keys := range [][3]string{{"@type", "@value", "@key"}, {"$type", "v", "k"}, {"_t", "value", "k"}, {"_t", "k", "key"}} {
+		t.Run(strings.Join(keys[:], "/"), func(t *testing.T) {
+			data, err := Marshal(input, MarshalTypeKey(keys[0]), MarshalValueKey(keys[1]), MarshalEntryKey(keys[2]))
+			assert.NoError(t, err)
+			assert.Contains(t, string(data), fmt.Sprintf(`[{%q:{%q:"github.com/trojanc/jsonr.TestKey",%q:{"tenant":"t"}},%q:`,
+				keys[2], keys[0], keys[1], keys[1]))
+
+			obj, err := Unmarshal(data, WithTypeKey(keys[0]), WithValueKey(keys[1]), WithEntryKey(keys[2]),
+				RegisterType(TestEvent{}), RegisterType(TestStruct{}), RegisterType(TestKey{}))
+			assert.NoError(t, err)
+			assert.Equal(t, input, obj)
+		})
+	}
+}
+
+func TestWrapOptions(t *testing.T) {
+	w, err := Wrap(1, MarshalTypeKey("$type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "int", w.Type)
+	assert.Equal(t, 1, w.Value)
+
+	data, err := json.Marshal(w)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"$type\":\"int\",\"v\":1}", string(data))
+
+	_, err = Wrap(1, MarshalValueKey(""))
+	assert.EqualError(t, err, "could not apply option: value key should not be empty")
+}
+
+func TestMarshalInline(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{
+			name: "Struct",
+			v:    TestStruct{String: "a", Int: 1},
+			want: `{"_t":"github.com/trojanc/jsonr.TestStruct","string":"a","int":1}`,
+		},
+		{
+			name: "Empty struct",
+			v:    TestStruct{},
+			want: `{"_t":"github.com/trojanc/jsonr.TestStruct"}`,
+		},
+		{
+			name: "Pointer to struct",
+			v:    &TestStruct{Int: 1},
+			want: `{"_t":"*github.com/trojanc/jsonr.TestStruct","int":1}`,
+		},
+		{
+			name: "Nested structs",
+			v:    TestEvent{Name: "a", Payload: TestStruct{Int: 1}, Items: []any{"b", TestLookup{"c": "d"}}},
+			want: `{"_t":"github.com/trojanc/jsonr.TestEvent","name":"a","payload":{"_t":"github.com/trojanc/jsonr.TestStruct","int":1},"items":[{"_t":"string","v":"b"},{"_t":"github.com/trojanc/jsonr.TestLookup","c":"d"}]}`,
+		},
+		{
+			name: "Map",
+			v:    map[string]any{"b": 1, "a": map[int]string{1: "c"}},
+			want: `{"_t":"map[string]interface","a":{"_t":"map[int]string","1":"c"},"b":{"_t":"int","v":1}}`,
+		},
+		{
+			name: "Primitives and slices stay wrapped",
+			v:    []any{1, []string{"a"}},
+			want: `{"_t":"[]interface","v":[{"_t":"int","v":1},{"_t":"[]string","v":["a"]}]}`,
+		},
+		{
+			name: "Map with non-textual keys stays wrapped",
+			v:    map[TestKey]int{{Tenant: "a"}: 1},
+			want: `{"_t":"map[github.com/trojanc/jsonr.TestKey]int","v":[{"k":{"tenant":"a"},"v":1}]}`,
+		},
+		{
+			name: "Struct with a member named like the type key stays wrapped",
+			v:    TestTyped{Type: "a"},
+			want: `{"_t":"github.com/trojanc/jsonr.TestTyped","v":{"_t":"a"}}`,
+		},
+		{
+			name: "Map with a member named like the value key stays wrapped",
+			v:    map[string]int{"v": 1},
+			want: `{"_t":"map[string]int","v":{"v":1}}`,
+		},
+		{
+			name: "Nil map stays wrapped",
+			v:    TestLookup(nil),
+			want: `{"_t":"github.com/trojanc/jsonr.TestLookup","v":null}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.v, MarshalInline())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			obj, err := Unmarshal(data, RegisterType(TestStruct{}), RegisterType(TestEvent{}),
+				RegisterType(TestLookup{}), RegisterType(TestKey{}), RegisterType(TestTyped{}))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.v, obj)
+		})
+	}
+}
+
+func TestMarshalInlineKeys(t *testing.T) {
+	data, err := Marshal(map[string]any{"a": TestStruct{Int: 1}}, MarshalInline(), MarshalTypeKey("@type"))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"@type":"map[string]interface","a":{"@type":"github.com/trojanc/jsonr.TestStruct","int":1}}`, string(data))
+
+	obj, err := Unmarshal(data, WithTypeKey("@type"), RegisterType(TestStruct{}))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": TestStruct{Int: 1}}, obj)
+}
+
+func TestMarshalMinimal(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       any
+		options []MarshalOption
+		want    string
+	}{
+		{
+			name: "Slice of structs",
+			v:    []TestStruct{{Int: 1}, {String: "a"}},
+			want: `[{"int":1},{"string":"a"}]`,
+		},
+		{
+			name: "Struct with interface fields",
+			v:    TestEvent{Name: "a", Payload: TestStruct{Int: 1}, Items: []any{"b"}},
+			want: `{"name":"a","payload":{"_t":"github.com/trojanc/jsonr.TestStruct","v":{"int":1}},"items":[{"_t":"string","v":"b"}]}`,
+		},
+		{
+			name: "Map of interfaces",
+			v:    map[string]any{"a": 1, "b": nil},
+			want: `{"a":{"_t":"int","v":1},"b":null}`,
+		},
+		{
+			name: "Pointer",
+			v:    &TestEvent{Name: "a"},
+			want: `{"name":"a"}`,
+		},
+		{
+			name:    "Inline",
+			v:       []any{TestStruct{Int: 1}},
+			options: []MarshalOption{MarshalInline()},
+			want:    `[{"_t":"github.com/trojanc/jsonr.TestStruct","int":1}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.v, append(tt.options, MarshalMinimal())...)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			obj, err := Unmarshal(data, WithRootType(tt.v), RegisterType(TestStruct{}))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.v, obj)
+		})
+	}
+}
+
+func TestMarshalMinimalNil(t *testing.T) {
+	data, err := Marshal(nil, MarshalMinimal())
+	assert.NoError(t, err)
+	assert.Equal(t, "null", string(data))
+
+	obj, err := Unmarshal(data, WithRootType(TestStruct{}))
+	assert.NoError(t, err)
+	assert.Equal(t, TestStruct{}, obj)
+}
+
+func TestMarshalCustomMarshalers(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{
+			name: "JSON marshaler",
+			v:    TestVersion{Major: 1, Minor: 2},
+			want: `{"_t":"github.com/trojanc/jsonr.TestVersion","v":"1.2"}`,
+		},
+		{
+			name: "Pointer to JSON marshaler",
+			v:    &TestVersion{Major: 1, Minor: 2},
+			want: `{"_t":"*github.com/trojanc/jsonr.TestVersion","v":"1.2"}`,
+		},
+		{
+			name: "Text marshaler with pointer receiver",
+			v:    TestAmount{cents: 1234},
+			want: `{"_t":"github.com/trojanc/jsonr.TestAmount","v":"12.34"}`,
+		},
+		{
+			name: "Text marshaler array",
+			v:    TestID{1, 2, 3, 4},
+			want: `{"_t":"github.com/trojanc/jsonr.TestID","v":"01020304"}`,
+		},
+		{
+			name: "Interface field of a JSON marshaler is not walked",
+			v:    TestRaw{Value: "a"},
+			want: `{"_t":"github.com/trojanc/jsonr.TestRaw","v":{"raw":"a"}}`,
+		},
+		{
+			name: "Nested in interfaces",
+			v:    map[string]any{"a": TestAmount{cents: 5}, "v": []any{TestVersion{Major: 3}}},
+			want: `{"_t":"map[string]interface","v":{"a":{"_t":"github.com/trojanc/jsonr.TestAmount","v":"0.05"},"v":{"_t":"[]interface","v":[{"_t":"github.com/trojanc/jsonr.TestVersion","v":"3.0"}]}}}`,
+		},
+		{
+			name: "Map keys and values",
+			v:    map[TestID]TestAmount{{1}: {cents: 100}},
+			want: `{"_t":"map[github.com/trojanc/jsonr.TestID]github.com/trojanc/jsonr.TestAmount","v":{"01000000":"1.00"}}`,
+		},
+		{
+			name: "Struct fields",
+			v:    TestPrice{Amount: TestAmount{cents: 250}},
+			want: `{"_t":"github.com/trojanc/jsonr.TestPrice","v":{"amount":"2.50"}}`,
+		},
+		{
+			name: "Map keys with pointer receivers",
+			v:    map[TestAmount]any{{cents: 1}: 2},
+			want: `{"_t":"map[github.com/trojanc/jsonr.TestAmount]interface","v":[{"k":"0.01","v":{"_t":"int","v":2}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.v)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			obj, err := Unmarshal(data, RegisterType(TestVersion{}), RegisterType(TestAmount{}),
+				RegisterType(TestID{}), RegisterType(TestRaw{}), RegisterType(TestPrice{}))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.v, obj)
+		})
+	}
+}
+
+func TestMarshalBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		v        any
+		encoding BytesEncoding
+		want     string
+	}{
+		{
+			name: "Bytes",
+			v:    []byte("hello"),
+			want: `{"_t":"bytes","v":"aGVsbG8="}`,
+		},
+		{
+			name:     "Hex",
+			v:        []byte("hello"),
+			encoding: BytesHex,
+			want:     `{"_t":"bytes","v":"68656c6c6f"}`,
+		},
+		{
+			name: "Nil bytes",
+			v:    []byte(nil),
+			want: `{"_t":"bytes","v":null}`,
+		},
+		{
+			name:     "Nested in interfaces",
+			v:        []any{[]byte{1, 2}, map[string][]byte{"a": {3}}},
+			encoding: BytesHex,
+			want:     `{"_t":"[]interface","v":[{"_t":"bytes","v":"0102"},{"_t":"map[string]bytes","v":{"a":"03"}}]}`,
+		},
+		{
+			name:     "Named byte slice",
+			v:        TestBlob{255},
+			encoding: BytesHex,
+			want:     `{"_t":"github.com/trojanc/jsonr.TestBlob","v":"ff"}`,
+		},
+		{
+			name:     "Struct fields",
+			v:        TestFile{Name: "a", Data: []byte{1}, Blob: TestBlob{2}},
+			encoding: BytesHex,
+			want:     `{"_t":"github.com/trojanc/jsonr.TestFile","v":{"name":"a","data":"01","blob":"02"}}`,
+		},
+		{
+			name: "Slice of byte slices",
+			v:    [][]byte{{1}, nil},
+			want: `{"_t":"[]bytes","v":["AQ==",null]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.v, MarshalBytesEncoding(tt.encoding))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			obj, err := Unmarshal(data, WithBytesEncoding(tt.encoding), RegisterType(TestBlob{}), RegisterType(TestFile{}))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.v, obj)
+		})
+	}
+}
+
+func TestUnmarshalBytes(t *testing.T) {
+	// Data written before byte slices were named "bytes"
+	obj, err := Unmarshal([]byte(`{"_t":"[]uint8","v":"aGVsbG8="}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello"), obj)
+
+	_, err = Unmarshal([]byte(`{"_t":"[]interface","v":[{"_t":"bytes","v":"zz"}]}`), WithBytesEncoding(BytesHex))
+	assert.EqualError(t, err, "error unmarshalling bytes at $.v[0].v: encoding/hex: invalid byte: U+007A 'z'")
+
+	_, err = Unmarshal([]byte(`{"_t":"bytes","v":1}`), WithBytesEncoding(BytesHex))
+	assert.Error(t, err)
+
+	_, err = Unmarshal(nil, WithBytesEncoding(3))
+	assert.EqualError(t, err, "could not apply option: unknown bytes encoding 3")
+
+	_, err = Marshal(nil, MarshalBytesEncoding(-1))
+	assert.EqualError(t, err, "could not apply option: unknown bytes encoding -1")
+}
+
+func TestMarshalComplex(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{
+			name: "Complex128",
+			v:    complex(1.5, -2),
+			want: `{"_t":"complex128","v":[1.5,-2]}`,
+		},
+		{
+			name: "Complex64",
+			v:    complex64(complex(0.1, 3)),
+			want: `{"_t":"complex64","v":[0.1,3]}`,
+		},
+		{
+			name: "Named complex",
+			v:    TestPhase(complex(0, 1)),
+			want: `{"_t":"github.com/trojanc/jsonr.TestPhase","v":[0,1]}`,
+		},
+		{
+			name: "Slice",
+			v:    []complex128{1, 2i},
+			want: `{"_t":"[]complex128","v":[[1,0],[0,2]]}`,
+		},
+		{
+			name: "Map keys and values",
+			v:    map[complex128]complex64{1i: 2},
+			want: `{"_t":"map[complex128]complex64","v":[{"k":[0,1],"v":[2,0]}]}`,
+		},
+		{
+			name: "Interfaces",
+			v:    map[string]any{"a": 1 + 1i, "b": []any{complex64(2)}},
+			want: `{"_t":"map[string]interface","v":{"a":{"_t":"complex128","v":[1,1]},"b":{"_t":"[]interface","v":[{"_t":"complex64","v":[2,0]}]}}}`,
+		},
+		{
+			name: "Pointer",
+			v:    ptr(complex(3, 4)),
+			want: `{"_t":"*complex128","v":[3,4]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.v)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			obj, err := Unmarshal(data, RegisterType(TestPhase(0)))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.v, obj)
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package jsonr
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// primitiveRegistry registry with only the primitive types, used when no Registry is provided
+var primitiveRegistry = NewRegistry()
+
+// Codec marshals and unmarshals values with their type information
+type Codec interface {
+	Marshal(input any, options ...MarshalOption) ([]byte, error)
+	Unmarshal(data []byte, options ...UnmarshalOption) (any, error)
+}
+
+// Registry is a set of types that can be unmarshalled. A Registry is built once and can be shared between calls to
+// Unmarshal using WithRegistry, or used directly as a Codec. It is safe for concurrent use. The zero value is a
+// Registry with only the primitive types, like the one returned by NewRegistry.
+//
+// Example usage:
+//
+//	registry := jsonr.NewRegistry()
+//	_ = registry.Register(Person{})
+//	_ = registry.Register(Car{})
+//
+//	data, _ := registry.Marshal(map[string]any{"person": Person{Name: "John"}})
+//	output, _ := registry.Unmarshal(data)
+type Registry struct {
+	mu    sync.RWMutex
+	types typeRegistry
+	// names the custom names of types registered with RegisterAs
+	names map[reflect.Type]string
+	// impls the implementations allowed for interfaces registered with RegisterInterface
+	impls implementations
+}
+
+var _ Codec = (*Registry)(nil)
+
+// primitiveTypes the types every Registry starts with
+var primitiveTypes = typeRegistry{
+	"int":        reflect.TypeOf(int(0)),
+	"int8":       reflect.TypeOf(int8(0)),
+	"int16":      reflect.TypeOf(int16(0)),
+	"int32":      reflect.TypeOf(int32(0)),
+	"int64":      reflect.TypeOf(int64(0)),
+	"uint":       reflect.TypeOf(uint(0)),
+	"uint8":      reflect.TypeOf(uint8(0)),
+	"uint16":     reflect.TypeOf(uint16(0)),
+	"uint32":     reflect.TypeOf(uint32(0)),
+	"uint64":     reflect.TypeOf(uint64(0)),
+	"float32":    reflect.TypeOf(float32(0)),
+	"float64":    reflect.TypeOf(float64(0)),
+	"complex64":  reflect.TypeOf(complex64(0)),
+	"complex128": reflect.TypeOf(complex128(0)),
+	"bool":       reflect.TypeOf(false),
+	"string":     reflect.TypeOf(""),
+	"bytes":      bytesType,
+	"byte":       reflect.TypeOf(byte(0)),
+	"rune":       reflect.TypeOf(rune(0)),
+	"interface":  reflect.TypeOf(new(any)).Elem(),
+}
+
+// NewRegistry creates a new Registry with all the primitive types registered
+func NewRegistry() *Registry {
+	return &Registry{types: primitiveTypes.clone()}
+}
+
+// Register registers a type that can be unmarshalled into an instance of the given type, see RegisterType.
+func (r *Registry) Register(instance any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.types == nil {
+		r.types = primitiveTypes.clone()
+	}
+	return r.types.register(instance)
+}
+
+// RegisterAs registers a type under a custom name, see RegisterTypeAs. Values marshalled by the registry will use
+// the custom name for the type, while the custom name and the aliases will all be unmarshalled into the type.
+//
+// Example usage:
+//
+//	registry := jsonr.NewRegistry()
+//	_ = registry.RegisterAs("billing.Invoice/v1", Invoice{}, "github.com/project/billing.Invoice")
+func (r *Registry) RegisterAs(name string, instance any, aliases ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Register in a copy first, so that a failing alias does not leave a partial registration
+	types := r.registered().clone()
+	if err := types.registerAs(name, instance, aliases...); err != nil {
+		return err
+	}
+
+	t := reflect.TypeOf(instance)
+	if existing, exists := r.names[t]; exists && existing != name {
+		return fmt.Errorf("%s is already registered as %q", t, existing)
+	}
+	if r.names == nil {
+		r.names = make(map[reflect.Type]string)
+	}
+	r.types = types
+	r.names[t] = name
+	return nil
+}
+
+// RegisterInterface registers an interface type with its allowed implementations, see RegisterInterface. The
+// interface type is passed as reflect.Type, as methods can not have type parameters.
+//
+// Example usage:
+//
+//	registry := jsonr.NewRegistry()
+//	_ = registry.RegisterInterface(reflect.TypeFor[Shape](), Circle{}, &Square{})
+func (r *Registry) RegisterInterface(iface reflect.Type, impls ...any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Register in copies first, so that a failing implementation does not leave a partial registration
+	types := r.registered().clone()
+	allowed := make(implementations, len(r.impls))
+	for i, t := range r.impls {
+		allowed[i] = t
+	}
+	if err := registerInterface(types, allowed, iface, impls); err != nil {
+		return err
+	}
+	r.types = types
+	r.impls = allowed
+	return nil
+}
+
+// Lookup finds a registered type by its name
+func (r *Registry) Lookup(name string) (reflect.Type, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, exists := r.registered()[name]
+	return t, exists
+}
+
+// Names returns the sorted names of all the registered types
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := r.registered()
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registered returns the registered types, a zero Registry has the primitive types. The lock should be held.
+func (r *Registry) registered() typeRegistry {
+	if r.types == nil {
+		return primitiveTypes
+	}
+	return r.types
+}
+
+// nameOf returns the custom name of a type registered with RegisterAs
+func (r *Registry) nameOf(t reflect.Type) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, exists := r.names[t]
+	return name, exists
+}
+
+// implementationsOf returns the implementations allowed for the interface
+func (r *Registry) implementationsOf(iface reflect.Type) []reflect.Type {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.impls[iface]
+}
+
+// Marshal encodes a Go value into JSON with type information, see Marshal. Types registered with RegisterAs are
+// marshalled with their custom name.
+func (r *Registry) Marshal(input any, options ...MarshalOption) ([]byte, error) {
+	return Marshal(input, append([]MarshalOption{MarshalRegistry(r)}, options...)...)
+}
+
+// Unmarshal decodes JSON data into a Go value using the types of the registry, see Unmarshal.
+func (r *Registry) Unmarshal(data []byte, options ...UnmarshalOption) (any, error) {
+	return Unmarshal(data, append([]UnmarshalOption{WithRegistry(r)}, options...)...)
+}
@@ -0,0 +1,171 @@
+package jsonr
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	assert.NoError(t, registry.Register(TestStruct{}))
+	assert.NoError(t, registry.Register(TestStatus("")))
+	assert.EqualError(t, registry.Register(&TestStruct{}), "only instance of named types should be used")
+
+	typ, ok := registry.Lookup("github.com/trojanc/jsonr.TestStruct")
+	assert.True(t, ok)
+	assert.Equal(t, reflect.TypeOf(TestStruct{}), typ)
+
+	typ, ok = registry.Lookup("int")
+	assert.True(t, ok)
+	assert.Equal(t, reflect.TypeOf(0), typ)
+
+	_, ok = registry.Lookup("github.com/trojanc/jsonr.TestEvent")
+	assert.False(t, ok)
+
+	names := registry.Names()
+	assert.Contains(t, names, "github.com/trojanc/jsonr.TestStruct")
+	assert.Contains(t, names, "github.com/trojanc/jsonr.TestStatus")
+	assert.Contains(t, names, "string")
+	assert.IsNonDecreasing(t, names)
+}
+
+func TestRegistryZeroValue(t *testing.T) {
+	var empty Registry
+	typ, ok := empty.Lookup("int")
+	assert.True(t, ok)
+	assert.Equal(t, reflect.TypeOf(0), typ)
+	assert.Equal(t, NewRegistry().Names(), empty.Names())
+
+	var registry Registry
+	assert.NoError(t, registry.Register(TestStruct{}))
+	assert.Contains(t, registry.Names(), "github.com/trojanc/jsonr.TestStruct")
+	assert.Contains(t, registry.Names(), "int")
+
+	registry = Registry{}
+	assert.NoError(t, registry.RegisterAs("test.Struct", TestStruct{}))
+	data, err := registry.Marshal([]any{TestStruct{Int: 1}, 2})
+	assert.NoError(t, err)
+	obj, err := registry.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{TestStruct{Int: 1}, 2}, obj)
+
+	registry = Registry{}
+	assert.NoError(t, registry.RegisterInterface(reflect.TypeFor[TestShape](), TestCircle{}))
+	_, ok = registry.Lookup("int")
+	assert.True(t, ok)
+}
+
+func TestRegistryCodec(t *testing.T) {
+	registry := NewRegistry()
+	assert.NoError(t, registry.Register(TestStruct{}))
+
+	var codec Codec = registry
+	input := map[string]any{
+		"a": TestStruct{String: "a"},
+		"b": &TestStruct{Int: 1},
+	}
+	data, err := codec.Marshal(input)
+	assert.NoError(t, err)
+
+	output, err := codec.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, input, output)
+
+	// Types registered for a single call do not modify the registry
+	data, err = codec.Marshal(TestEvent{Name: "a", Payload: TestStruct{Int: 1}})
+	assert.NoError(t, err)
+	output, err = codec.Unmarshal(data, RegisterType(TestEvent{}))
+	assert.NoError(t, err)
+	assert.Equal(t, TestEvent{Name: "a", Payload: TestStruct{Int: 1}}, output)
+
+	_, ok := registry.Lookup("github.com/trojanc/jsonr.TestEvent")
+	assert.False(t, ok)
+	_, err = codec.Unmarshal(data)
+	assert.EqualError(t, err, "unknown type \"github.com/trojanc/jsonr.TestEvent\" at $")
+}
+
+func TestRegistryConcurrentUse(t *testing.T) {
+	registry := NewRegistry()
+	assert.NoError(t, registry.Register(TestStruct{}))
+
+	data, err := Marshal([]any{TestStruct{Int: 1}, TestStruct{Int: 2}})
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			output, err := Unmarshal(data, WithRegistry(registry))
+			assert.NoError(t, err)
+			assert.Equal(t, []any{TestStruct{Int: 1}, TestStruct{Int: 2}}, output)
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, registry.Register(TestEvent{}))
+			assert.NotEmpty(t, registry.Names())
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRegistryRegisterAs(t *testing.T) {
+	registry := NewRegistry()
+	assert.NoError(t, registry.RegisterAs("test.Struct/v1", TestStruct{}, "github.com/trojanc/jsonr.TestStruct", "test.Struct/v0"))
+	assert.NoError(t, registry.RegisterAs("test.Struct/v1", TestStruct{}))
+
+	data, err := registry.Marshal(map[string]any{
+		"a": TestStruct{Int: 1},
+		"b": []*TestStruct{{Int: 2}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"_t\":\"map[string]interface\",\"v\":{\"a\":{\"_t\":\"test.Struct/v1\",\"v\":{\"int\":1}},\"b\":{\"_t\":\"[]*test.Struct/v1\",\"v\":[{\"int\":2}]}}}", string(data))
+
+	output, err := registry.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"a": TestStruct{Int: 1},
+		"b": []*TestStruct{{Int: 2}},
+	}, output)
+
+	// The aliases still unmarshal into the type
+	for _, name := range []string{"github.com/trojanc/jsonr.TestStruct", "test.Struct/v0"} {
+		output, err = registry.Unmarshal([]byte(`{"_t":"` + name + `","v":{"int":3}}`))
+		assert.NoError(t, err)
+		assert.Equal(t, TestStruct{Int: 3}, output)
+	}
+
+	// Package level functions are not affected by the registry, unless it is passed as an option
+	data, err = Marshal(TestStruct{Int: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"_t\":\"github.com/trojanc/jsonr.TestStruct\",\"v\":{\"int\":1}}", string(data))
+
+	data, err = Marshal(TestStruct{Int: 1}, MarshalRegistry(registry))
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"_t\":\"test.Struct/v1\",\"v\":{\"int\":1}}", string(data))
+
+	data, err = registry.Marshal(TestStruct{Int: 1}, MarshalValueKey("value"))
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"_t\":\"test.Struct/v1\",\"value\":{\"int\":1}}", string(data))
+}
+
+func TestRegistryRegisterAsErrors(t *testing.T) {
+	registry := NewRegistry()
+	assert.NoError(t, registry.RegisterAs("test.Struct/v1", TestStruct{}))
+
+	assert.EqualError(t, registry.RegisterAs("test.Struct/v1", TestStructPtrs{}), "type name \"test.Struct/v1\" is already registered for jsonr.TestStruct")
+	assert.EqualError(t, registry.RegisterAs("test.Struct/v2", TestStruct{}), "jsonr.TestStruct is already registered as \"test.Struct/v1\"")
+	assert.EqualError(t, registry.RegisterAs("int", TestStatus("")), "type name \"int\" is already registered for int")
+	assert.EqualError(t, registry.RegisterAs("test.Ptrs", &TestStructPtrs{}), "only instance of named types should be used")
+	assert.EqualError(t, registry.RegisterAs("test.Status", TestStatus(""), "[]test.Status"), "invalid type name \"[]test.Status\"")
+
+	// A failed registration does not register any of the names
+	_, ok := registry.Lookup("test.Status")
+	assert.False(t, ok)
+
+	for _, name := range []string{"", "*test.Struct", "map[a]b", "test Struct", "test.Struct[int]"} {
+		assert.EqualError(t, registry.RegisterAs(name, TestStructPtrs{}), "invalid type name \""+name+"\"")
+	}
+}
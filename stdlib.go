@@ -0,0 +1,71 @@
+package jsonr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// urlType is marshalled as a string by the stdlib options, as url.URL has no marshal methods of its own
+var urlType = reflect.TypeFor[url.URL]()
+
+// stdlibTypes the standard library types registered by WithStdlibTypes
+var stdlibTypes = map[reflect.Type]bool{
+	reflect.TypeFor[time.Time]():     true,
+	reflect.TypeFor[time.Duration](): true,
+	reflect.TypeFor[big.Int]():       true,
+	reflect.TypeFor[net.IP]():        true,
+	urlType:                          true,
+	reflect.TypeFor[json.Number]():   true,
+}
+
+// WithStdlibTypes registers common standard library types: time.Time, time.Duration, big.Int, net.IP, url.URL and
+// json.Number. They are registered by their fully qualified names, like "time.Time" and "math/big.Int". A url.URL
+// is unmarshalled from a string, as written by MarshalStdlibTypes.
+func WithStdlibTypes() UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if opts.typeRegistry == nil {
+			opts.typeRegistry = make(typeRegistry)
+		}
+		for t := range stdlibTypes {
+			if err := opts.typeRegistry.registerType(t); err != nil {
+				return err
+			}
+		}
+		opts.stdlib = true
+		return nil
+	}
+}
+
+// MarshalStdlibTypes writes the standard library types of WithStdlibTypes with their fully qualified names, whatever
+// the type name strategy, and writes a url.URL as a string instead of its fields.
+func MarshalStdlibTypes() MarshalOption {
+	return func(opts *marshalOptions) error {
+		opts.stdlib = true
+		return nil
+	}
+}
+
+// marshalURL returns the URL as string
+func marshalURL(v reflect.Value) string {
+	u := v.Interface().(url.URL)
+	return u.String()
+}
+
+// unmarshalURL parses the URL found at the JSON path into v, a URL that is not a string is decoded from its fields
+func unmarshalURL(raw json.RawMessage, v reflect.Value, opts *unmarshalOptions, path string) error {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return unwrapStruct(raw, v, opts, path)
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid URL at %s: %s", path, err.Error())
+	}
+	v.Set(reflect.ValueOf(*u))
+	return nil
+}
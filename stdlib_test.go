@@ -0,0 +1,95 @@
+package jsonr
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestStdlibTypes(t *testing.T) {
+	u, err := url.Parse("https://user@example.com/a?b=c#d")
+	assert.NoError(t, err)
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{
+			name: "Time",
+			v:    time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+			want: `{"_t":"time.Time","v":"2024-01-02T03:04:05.000000006Z"}`,
+		},
+		{
+			name: "Duration",
+			v:    90 * time.Minute,
+			want: `{"_t":"time.Duration","v":5400000000000}`,
+		},
+		{
+			name: "Big int",
+			v:    *new(big.Int).Lsh(big.NewInt(1), 100),
+			want: `{"_t":"math/big.Int","v":1267650600228229401496703205376}`,
+		},
+		{
+			name: "Pointer to big int",
+			v:    big.NewInt(-5),
+			want: `{"_t":"*math/big.Int","v":-5}`,
+		},
+		{
+			name: "IP",
+			v:    net.ParseIP("192.168.0.1"),
+			want: `{"_t":"net.IP","v":"192.168.0.1"}`,
+		},
+		{
+			name: "URL",
+			v:    *u,
+			want: `{"_t":"net/url.URL","v":"https://user@example.com/a?b=c#d"}`,
+		},
+		{
+			name: "Pointer to URL",
+			v:    u,
+			want: `{"_t":"*net/url.URL","v":"https://user@example.com/a?b=c#d"}`,
+		},
+		{
+			name: "Number",
+			v:    json.Number("1.50"),
+			want: `{"_t":"encoding/json.Number","v":1.50}`,
+		},
+		{
+			name: "Nested",
+			v:    map[string]any{"at": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "links": []*url.URL{u}},
+			want: `{"_t":"map[string]interface","at":{"_t":"time.Time","v":"2024-01-02T00:00:00Z"},"links":{"_t":"[]*net/url.URL","v":["https://user@example.com/a?b=c#d"]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.v, MarshalStdlibTypes(), MarshalTypeNames(ShortTypeNames), MarshalInline())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			obj, err := Unmarshal(data, WithStdlibTypes())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.v, obj)
+		})
+	}
+}
+
+func TestStdlibTypesURL(t *testing.T) {
+	// Without the options a URL is written as a struct, which can still be unmarshalled
+	data, err := Marshal(url.URL{Scheme: "https", Host: "example.com"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"Scheme":"https"`)
+
+	obj, err := Unmarshal(data, WithStdlibTypes())
+	assert.NoError(t, err)
+	assert.Equal(t, url.URL{Scheme: "https", Host: "example.com"}, obj)
+
+	_, err = Unmarshal([]byte(`{"_t":"[]net/url.URL","v":[":"]}`), WithStdlibTypes())
+	assert.EqualError(t, err, "invalid URL at $.v[0]: parse \":\": missing protocol scheme")
+
+	_, err = Unmarshal([]byte(`{"_t":"time.Duration","v":1}`))
+	assert.EqualError(t, err, "unknown type \"time.Duration\" at $")
+}
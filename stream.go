@@ -0,0 +1,138 @@
+package jsonr
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Encoder writes values with type information to an output stream, mirroring json.Encoder. Every value is written
+// as a separate line, producing newline delimited JSON.
+//
+// Example usage:
+//
+//	enc := jsonr.NewEncoder(os.Stdout)
+//	_ = enc.Encode(Person{Name: "John", Age: 30})
+//	_ = enc.Encode(Car{Make: "BMW", Model: "X6"})
+//	// {"_t":"main.Person","v":{"Name":"John","Age":30}}
+//	// {"_t":"main.Car","v":{"Make":"BMW","Model":"X6"}}
+type Encoder struct {
+	enc  *json.Encoder
+	opts marshalOptions
+	err  error
+}
+
+// NewEncoder returns a new encoder that writes to w. The options are applied once, and used for every value that
+// is encoded. An error applying the options is returned by Encode.
+func NewEncoder(w io.Writer, options ...MarshalOption) *Encoder {
+	e := &Encoder{
+		enc: json.NewEncoder(w),
+	}
+	opts, err := applyMarshalOptions(options...)
+	if err != nil {
+		e.err = err
+		return e
+	}
+	e.opts = *opts
+	e.enc.SetIndent(opts.prefix, opts.indent)
+	e.enc.SetEscapeHTML(opts.escapeHTML)
+	return e
+}
+
+// Encode writes the value with its type information to the stream, followed by a newline
+func (e *Encoder) Encode(v any) error {
+	if e.err != nil {
+		return e.err
+	}
+	w, err := annotate(v, &e.opts)
+	if err != nil {
+		return err
+	}
+	return e.enc.Encode(w)
+}
+
+// SetIndent indents every following value, see json.Encoder.SetIndent
+func (e *Encoder) SetIndent(prefix, indent string) {
+	e.opts.prefix, e.opts.indent = prefix, indent
+	e.enc.SetIndent(prefix, indent)
+}
+
+// SetEscapeHTML specifies if problematic HTML characters should be escaped, see json.Encoder.SetEscapeHTML
+func (e *Encoder) SetEscapeHTML(on bool) {
+	e.opts.escapeHTML = on
+	e.enc.SetEscapeHTML(on)
+}
+
+// Decoder reads values with type information from an input stream, mirroring json.Decoder. The stream can contain
+// a sequence of values, either newline delimited or concatenated.
+//
+// Example usage:
+//
+//	dec := jsonr.NewDecoder(file, jsonr.RegisterType(Person{}), jsonr.RegisterType(Car{}))
+//	for dec.More() {
+//	    value, err := dec.Decode()
+//	    if err != nil {
+//	        return err
+//	    }
+//	    // value will be Person{...} or Car{...}
+//	}
+type Decoder struct {
+	dec  *json.Decoder
+	opts *unmarshalOptions
+	err  error
+	// limit limits the bytes read for every value, see WithMaxBytes
+	limit *limitReader
+}
+
+// NewDecoder returns a new decoder that reads from r. The options are applied once, and used for every value that
+// is decoded. An error applying the options is returned by Decode.
+func NewDecoder(r io.Reader, options ...UnmarshalOption) *Decoder {
+	opts, err := applyUnmarshalOptions(options...)
+	d := &Decoder{
+		opts: opts,
+		err:  err,
+	}
+	if err == nil && opts.limits.bytes > 0 {
+		d.limit = &limitReader{r: r, max: opts.limits.bytes}
+		d.limit.reset(0)
+		r = d.limit
+	}
+	d.dec = json.NewDecoder(r)
+	return d
+}
+
+// More reports whether there is another value in the stream
+func (d *Decoder) More() bool {
+	return d.err == nil && d.dec.More()
+}
+
+// Decode reads the next value from the stream. At the end of the stream io.EOF is returned.
+func (d *Decoder) Decode() (any, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		var limitErr *LimitError
+		if errors.As(err, &limitErr) {
+			return nil, limitErr
+		}
+		return nil, err
+	}
+	if d.limit != nil {
+		// More reads ahead to the start of the next value
+		d.limit.reset(d.dec.InputOffset())
+	}
+	if err := checkLimits(raw, d.opts); err != nil {
+		return nil, err
+	}
+	if d.opts.minimal {
+		return unmarshalRoot(raw, d.opts)
+	}
+	wrapper, err := readEnvelope(raw, d.opts, "$")
+	if err != nil {
+		return nil, err
+	}
+	return Unwrap(wrapper, d.opts)
+}
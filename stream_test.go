@@ -0,0 +1,111 @@
+package jsonr
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEncoderAndDecoder(t *testing.T) {
+	values := []any{
+		TestStruct{Int: 1},
+		&TestStruct{String: "<a>"},
+		nil,
+		map[string]any{"a": TestEvent{Name: "created", Payload: 1}},
+		"test",
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, v := range values {
+		assert.NoError(t, enc.Encode(v))
+	}
+	assert.Equal(t, "{\"_t\":\"github.com/trojanc/jsonr.TestStruct\",\"v\":{\"int\":1}}\n"+
+		"{\"_t\":\"*github.com/trojanc/jsonr.TestStruct\",\"v\":{\"string\":\"\\u003ca\\u003e\"}}\n"+
+		"null\n"+
+		"{\"_t\":\"map[string]interface\",\"v\":{\"a\":{\"_t\":\"github.com/trojanc/jsonr.TestEvent\",\"v\":{\"name\":\"created\",\"payload\":{\"_t\":\"int\",\"v\":1}}}}}\n"+
+		"{\"_t\":\"string\",\"v\":\"test\"}\n", buf.String())
+
+	dec := NewDecoder(&buf, RegisterType(TestStruct{}), RegisterType(TestEvent{}))
+	var decoded []any
+	for dec.More() {
+		v, err := dec.Decode()
+		assert.NoError(t, err)
+		decoded = append(decoded, v)
+	}
+	assert.Equal(t, values, decoded)
+
+	_, err := dec.Decode()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestEncoderOptions(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetIndent("", " ")
+	enc.SetEscapeHTML(false)
+	assert.NoError(t, enc.Encode(TestStruct{String: "<a>"}))
+	assert.Equal(t, "{\n \"_t\": \"github.com/trojanc/jsonr.TestStruct\",\n \"v\": {\n  \"string\": \"<a>\"\n }\n}\n", buf.String())
+
+	assert.Error(t, enc.Encode(map[string]any{"a": func() {}}))
+
+	buf.Reset()
+	enc = NewEncoder(&buf, MarshalIndent("", " "), MarshalEscapeHTML(false), MarshalTypeKey("@type"))
+	assert.NoError(t, enc.Encode([]any{"<a>"}))
+	assert.Equal(t, "{\n \"@type\": \"[]interface\",\n \"v\": [\n  {\n   \"@type\": \"string\",\n   \"v\": \"<a>\"\n  }\n ]\n}\n", buf.String())
+
+	buf.Reset()
+	enc = NewEncoder(&buf, MarshalMinimal())
+	assert.NoError(t, enc.Encode([]any{1}))
+	assert.NoError(t, enc.Encode([]any{"a"}))
+	assert.Equal(t, "[{\"_t\":\"int\",\"v\":1}]\n[{\"_t\":\"string\",\"v\":\"a\"}]\n", buf.String())
+
+	dec := NewDecoder(&buf, WithRootType([]any{}))
+	for _, want := range [][]any{{1}, {"a"}} {
+		v, err := dec.Decode()
+		assert.NoError(t, err)
+		assert.Equal(t, want, v)
+	}
+
+	enc = NewEncoder(&buf, MarshalTypeKey(""))
+	assert.EqualError(t, enc.Encode(1), "could not apply option: type key should not be empty")
+}
+
+func TestDecoderConcatenated(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"_t":"int","v":1}{"_t":"string","v":"a"} [1]`))
+
+	v, err := dec.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	assert.True(t, dec.More())
+	v, err = dec.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", v)
+
+	assert.True(t, dec.More())
+	_, err = dec.Decode()
+	assert.Error(t, err)
+}
+
+func TestDecoderErrors(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`{"_t":"int","v":1}`), RegisterType(func() {}))
+	assert.False(t, dec.More())
+	_, err := dec.Decode()
+	assert.EqualError(t, err, "could not apply option: only instance of named types should be used")
+
+	dec = NewDecoder(strings.NewReader(`{"_t":"example.Person","v":{}}`))
+	_, err = dec.Decode()
+	assert.EqualError(t, err, "unknown type \"example.Person\" at $")
+
+	dec = NewDecoder(strings.NewReader(`{"@type":"int","@value":1}`), WithTypeKey("@type"), WithValueKey("@value"))
+	v, err := dec.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	dec = NewDecoder(strings.NewReader(`{"_t":"int",`))
+	_, err = dec.Decode()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
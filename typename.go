@@ -0,0 +1,524 @@
+package jsonr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// typeExprKind the kind of type a typeExpr describes
+type typeExprKind int
+
+const (
+	namedExpr typeExprKind = iota
+	pointerExpr
+	sliceExpr
+	arrayExpr
+	mapExpr
+	structExpr
+)
+
+// typeExpr a parsed type name, like "map[string][]*example.Person"
+type typeExpr struct {
+	kind typeExprKind
+	// name the name of a named type, like "int" or "example.Person"
+	name string
+	// args the type arguments of an instantiated generic type
+	args []*typeExpr
+	// length the length of an array
+	length int
+	// key the key type of a map
+	key *typeExpr
+	// elem the element type of pointers, slices, arrays and maps
+	elem *typeExpr
+	// fields the fields of an anonymous struct
+	fields []structFieldExpr
+}
+
+// structFieldExpr a field of an anonymous struct, like "Name string `json:\"name\"`"
+type structFieldExpr struct {
+	// name the name of the field, empty for embedded fields
+	name string
+	// typ the type of the field
+	typ *typeExpr
+	// tag the tag of the field
+	tag string
+}
+
+// String formats the type name, parsing the result gives back the same expression
+func (e *typeExpr) String() string {
+	var sb strings.Builder
+	e.write(&sb)
+	return sb.String()
+}
+
+// write writes the type name to the builder
+func (e *typeExpr) write(sb *strings.Builder) {
+	switch e.kind {
+	case pointerExpr:
+		sb.WriteByte('*')
+	case sliceExpr:
+		sb.WriteString("[]")
+	case arrayExpr:
+		sb.WriteByte('[')
+		sb.WriteString(strconv.Itoa(e.length))
+		sb.WriteByte(']')
+	case mapExpr:
+		sb.WriteString("map[")
+		e.key.write(sb)
+		sb.WriteByte(']')
+	case structExpr:
+		sb.WriteString("struct{")
+		for i, f := range e.fields {
+			if i > 0 {
+				sb.WriteByte(';')
+			}
+			if f.name != "" {
+				sb.WriteString(f.name)
+				sb.WriteByte(' ')
+			}
+			f.typ.write(sb)
+			if f.tag != "" {
+				sb.WriteByte(' ')
+				if strconv.CanBackquote(f.tag) {
+					sb.WriteString("`" + f.tag + "`")
+				} else {
+					sb.WriteString(strconv.Quote(f.tag))
+				}
+			}
+		}
+		sb.WriteByte('}')
+		return
+	default:
+		sb.WriteString(e.name)
+		if len(e.args) > 0 {
+			sb.WriteByte('[')
+			for i, arg := range e.args {
+				if i > 0 {
+					sb.WriteByte(',')
+				}
+				arg.write(sb)
+			}
+			sb.WriteByte(']')
+		}
+		return
+	}
+	e.elem.write(sb)
+}
+
+// hasStruct reports if the expression contains an anonymous struct at any depth
+func (e *typeExpr) hasStruct() bool {
+	if e == nil {
+		return false
+	}
+	if e.kind == structExpr {
+		return true
+	}
+	for _, arg := range e.args {
+		if arg.hasStruct() {
+			return true
+		}
+	}
+	return e.key.hasStruct() || e.elem.hasStruct()
+}
+
+// TypeSyntaxError is returned when a type name in the data can not be parsed
+type TypeSyntaxError struct {
+	// Type the type name that could not be parsed
+	Type string
+	// Offset the position in the type name where the error was found
+	Offset int
+	// Msg a description of the error
+	Msg string
+	// Path the JSON path of the value with the type name
+	Path string
+}
+
+func (e *TypeSyntaxError) Error() string {
+	return fmt.Sprintf("invalid type %q at %s: %s at offset %d", e.Type, e.Path, e.Msg, e.Offset)
+}
+
+// typeParser parses type names following the grammar:
+//
+//	type  = "*" type | "[" "]" type | "[" length "]" type | "map" "[" type "]" type | struct |
+//	        name [ "[" type { "," type } "]" ]
+//	struct = "struct" "{" [ field { ";" field } ] "}"
+//	field = [ name " " ] type [ " " tag ]
+//	name  = any characters except "[", "]", "{", "}", ",", ";", quotes and white space, not starting with "*"
+//	tag   = a Go string literal, like `json:"name"`
+type typeParser struct {
+	s   string
+	pos int
+	// depth the nesting of the type being parsed
+	depth int
+}
+
+// maxTypeDepth the maximum nesting of type names, deeper names are rejected before they exhaust the stack
+const maxTypeDepth = 100
+
+// parseTypeName parses a type name into an expression
+func parseTypeName(s string) (*typeExpr, error) {
+	p := &typeParser{s: s}
+	e, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	return e, nil
+}
+
+// parseType parses a single type starting at the current position
+func (p *typeParser) parseType() (*typeExpr, error) {
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("unexpected end of type")
+	}
+	if p.depth >= maxTypeDepth {
+		return nil, p.errorf("type nested deeper than %d", maxTypeDepth)
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+
+	switch {
+	case p.s[p.pos] == '*':
+		p.pos++
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return &typeExpr{kind: pointerExpr, elem: elem}, nil
+	case p.s[p.pos] == '[':
+		p.pos++
+		if p.consume(']') {
+			elem, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			return &typeExpr{kind: sliceExpr, elem: elem}, nil
+		}
+		length, err := p.parseLength()
+		if err != nil {
+			return nil, err
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return &typeExpr{kind: arrayExpr, length: length, elem: elem}, nil
+	case strings.HasPrefix(p.s[p.pos:], "map["):
+		p.pos += len("map[")
+		key, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(']') {
+			return nil, p.expected("]")
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return &typeExpr{kind: mapExpr, key: key, elem: elem}, nil
+	case strings.HasPrefix(p.s[p.pos:], "struct{"):
+		p.pos += len("struct{")
+		e := &typeExpr{kind: structExpr}
+		if p.consume('}') {
+			return e, nil
+		}
+		for {
+			f, err := p.parseField()
+			if err != nil {
+				return nil, err
+			}
+			e.fields = append(e.fields, f)
+			if p.consume('}') {
+				return e, nil
+			}
+			if !p.consume(';') {
+				return nil, p.expected("; or }")
+			}
+		}
+	default:
+		return p.parseNamed()
+	}
+}
+
+// parseField parses a field of an anonymous struct, the name is omitted for embedded fields
+func (p *typeParser) parseField() (structFieldExpr, error) {
+	start := p.pos
+	typ, err := p.parseType()
+	if err != nil {
+		return structFieldExpr{}, err
+	}
+	f := structFieldExpr{typ: typ}
+
+	// A space that is not followed by a tag separates the name of the field from its type
+	if p.pos+1 < len(p.s) && p.s[p.pos] == ' ' && !isQuote(p.s[p.pos+1]) {
+		if typ.kind != namedExpr || len(typ.args) > 0 {
+			p.pos = start
+			return structFieldExpr{}, p.errorf("invalid field name")
+		}
+		p.pos++
+		f.name = typ.name
+		if f.typ, err = p.parseType(); err != nil {
+			return structFieldExpr{}, err
+		}
+	}
+
+	if p.pos+1 < len(p.s) && p.s[p.pos] == ' ' && isQuote(p.s[p.pos+1]) {
+		p.pos++
+		lit, err := strconv.QuotedPrefix(p.s[p.pos:])
+		if err != nil {
+			return structFieldExpr{}, p.errorf("invalid tag")
+		}
+		f.tag, _ = strconv.Unquote(lit)
+		p.pos += len(lit)
+	}
+	return f, nil
+}
+
+// isQuote reports if the character starts a Go string literal
+func isQuote(c byte) bool {
+	return c == '"' || c == '`'
+}
+
+// parseLength parses the length of an array up to the closing bracket
+func (p *typeParser) parseLength() (int, error) {
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return 0, p.expected("] or array length")
+	}
+	length, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, p.errorf("invalid array length")
+	}
+	if !p.consume(']') {
+		return 0, p.expected("]")
+	}
+	return length, nil
+}
+
+// parseNamed parses a named type with its optional type arguments
+func (p *typeParser) parseNamed() (*typeExpr, error) {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune("[]{},;\"`\t\n\r ", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	e := &typeExpr{kind: namedExpr, name: p.s[start:p.pos]}
+
+	if !p.consume('[') {
+		return e, nil
+	}
+	for {
+		arg, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		e.args = append(e.args, arg)
+		if p.consume(']') {
+			return e, nil
+		}
+		if !p.consume(',') {
+			return nil, p.expected(", or ]")
+		}
+	}
+}
+
+// consume skips the character c when it is found at the current position
+func (p *typeParser) consume(c byte) bool {
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expected returns an error for a missing token at the current position
+func (p *typeParser) expected(token string) error {
+	if p.pos >= len(p.s) {
+		return p.errorf("expected %s, found end of type", token)
+	}
+	return p.errorf("expected %s, found %q", token, p.s[p.pos])
+}
+
+// errorf returns a syntax error at the current position
+func (p *typeParser) errorf(format string, args ...any) error {
+	return &TypeSyntaxError{Type: p.s, Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// typeExprOf builds the expression naming the type t
+func typeExprOf(t reflect.Type, opts *marshalOptions) *typeExpr {
+	// Types registered with a custom name use that name
+	if name, ok := opts.registry.nameOf(t); ok {
+		return &typeExpr{kind: namedExpr, name: name}
+	}
+
+	// Named types are identified by their fully qualified name, unless the strategy names them differently
+	if opts.stdlib && stdlibTypes[t] {
+		return &typeExpr{kind: namedExpr, name: FullTypeNames(t)}
+	}
+	if t.Name() != "" && t.PkgPath() != "" {
+		if name := opts.typeNames(t); isValidTypeName(name) {
+			return &typeExpr{kind: namedExpr, name: name}
+		}
+		// Generic types are named with their type arguments
+		if e, err := parseTypeName(FullTypeNames(t)); err == nil {
+			return e
+		}
+		return &typeExpr{kind: namedExpr, name: FullTypeNames(t)}
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		if t == bytesType {
+			return &typeExpr{kind: namedExpr, name: "bytes"}
+		}
+		return &typeExpr{kind: sliceExpr, elem: typeExprOf(t.Elem(), opts)}
+	case reflect.Array:
+		return &typeExpr{kind: arrayExpr, length: t.Len(), elem: typeExprOf(t.Elem(), opts)}
+	case reflect.Map:
+		return &typeExpr{kind: mapExpr, key: typeExprOf(t.Key(), opts), elem: typeExprOf(t.Elem(), opts)}
+	case reflect.Ptr:
+		return &typeExpr{kind: pointerExpr, elem: typeExprOf(t.Elem(), opts)}
+	case reflect.Struct:
+		// Anonymous structs are described by their fields, so they can be created again without registration
+		e := &typeExpr{kind: structExpr}
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			f := structFieldExpr{typ: typeExprOf(sf.Type, opts), tag: string(sf.Tag)}
+			if !sf.Anonymous {
+				f.name = sf.Name
+			}
+			e.fields = append(e.fields, f)
+		}
+		return e
+	default:
+		return &typeExpr{kind: namedExpr, name: t.Kind().String()}
+	}
+}
+
+// maxArraySize the maximum size in bytes of arrays created from type names. Arrays are allocated before their
+// elements are read, so without a maximum a type name like [999999999999]int8 would exhaust the memory.
+const maxArraySize = 1 << 20
+
+// resolveTypeExpr resolves the expression into a reflection type, nil is returned for unknown types
+func resolveTypeExpr(e *typeExpr, opts *unmarshalOptions) (reflect.Type, error) {
+	switch e.kind {
+	case pointerExpr:
+		elem, err := resolveTypeExpr(e.elem, opts)
+		if elem == nil || err != nil {
+			return nil, err
+		}
+		return reflect.PointerTo(elem), nil
+	case sliceExpr:
+		elem, err := resolveTypeExpr(e.elem, opts)
+		if elem == nil || err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(elem), nil
+	case arrayExpr:
+		elem, err := resolveTypeExpr(e.elem, opts)
+		if elem == nil || err != nil {
+			return nil, err
+		}
+		if !opts.arrays {
+			return nil, errors.New("arrays are not allowed, see WithArrays")
+		}
+		if elem.Size() > 0 && uintptr(e.length) > maxArraySize/elem.Size() {
+			return nil, fmt.Errorf("array %s is larger than %d bytes", e, maxArraySize)
+		}
+		return reflect.ArrayOf(e.length, elem), nil
+	case mapExpr:
+		key, err := resolveTypeExpr(e.key, opts)
+		if key == nil || err != nil {
+			return nil, err
+		}
+		elem, err := resolveTypeExpr(e.elem, opts)
+		if elem == nil || err != nil || !key.Comparable() {
+			return nil, err
+		}
+		return reflect.MapOf(key, elem), nil
+	case structExpr:
+		return resolveStruct(e, opts)
+	default:
+		if t, exists := opts.lookup(e.String()); exists {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+// resolveStruct creates the anonymous struct described by the expression, see WithAnonymousStructs
+func resolveStruct(e *typeExpr, opts *unmarshalOptions) (t reflect.Type, err error) {
+	if !opts.anonymousStructs {
+		return nil, errors.New("anonymous structs are not allowed, see WithAnonymousStructs")
+	}
+
+	fields := make([]reflect.StructField, len(e.fields))
+	for i, f := range e.fields {
+		typ, err := resolveTypeExpr(f.typ, opts)
+		if typ == nil || err != nil {
+			return nil, err
+		}
+		fields[i] = reflect.StructField{Name: f.name, Type: typ, Tag: reflect.StructTag(f.tag)}
+		if f.name == "" {
+			// Embedded fields are named after their type
+			fields[i].Anonymous = true
+			if typ.Kind() == reflect.Ptr {
+				typ = typ.Elem()
+			}
+			fields[i].Name = typ.Name()
+		}
+	}
+
+	// StructOf panics on fields it can not create, like unexported fields
+	defer func() {
+		if r := recover(); r != nil {
+			t, err = nil, fmt.Errorf("could not create anonymous struct: %v", r)
+		}
+	}()
+	return reflect.StructOf(fields), nil
+}
+
+// genericName converts the name of an instantiated generic type, as reported by reflect, into its canonical form
+// where the type arguments are named like other type names, e.g. Page[interface {}] becomes Page[interface] and
+// Page[[]uint8] becomes Page[bytes]. Names that can not be parsed, like type arguments that are anonymous structs,
+// are returned as is.
+func genericName(name string) string {
+	if !strings.Contains(name, "[") {
+		return name
+	}
+	name = strings.ReplaceAll(name, "interface {}", "interface")
+	e, err := parseTypeName(name)
+	if err != nil {
+		return name
+	}
+	canonicalArgs(e)
+	return e.String()
+}
+
+// canonicalArgs rewrites the expression to use the names of typeExprOf, like bytes instead of []uint8
+func canonicalArgs(e *typeExpr) {
+	if e == nil {
+		return
+	}
+	if e.kind == sliceExpr && e.elem.kind == namedExpr && e.elem.name == "uint8" && len(e.elem.args) == 0 {
+		*e = typeExpr{kind: namedExpr, name: "bytes"}
+		return
+	}
+	canonicalArgs(e.key)
+	canonicalArgs(e.elem)
+	for _, arg := range e.args {
+		canonicalArgs(arg)
+	}
+}
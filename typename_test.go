@@ -0,0 +1,335 @@
+package jsonr
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTypeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want *typeExpr
+	}{
+		{
+			name: "int",
+			want: &typeExpr{kind: namedExpr, name: "int"},
+		},
+		{
+			name: "*github.com/trojanc/jsonr.TestStruct",
+			want: &typeExpr{kind: pointerExpr, elem: &typeExpr{kind: namedExpr, name: "github.com/trojanc/jsonr.TestStruct"}},
+		},
+		{
+			name: "[][3]bytes",
+			want: &typeExpr{kind: sliceExpr, elem: &typeExpr{kind: arrayExpr, length: 3, elem: &typeExpr{kind: namedExpr, name: "bytes"}}},
+		},
+		{
+			name: "map[[2]int]map[string]*interface",
+			want: &typeExpr{
+				kind: mapExpr,
+				key:  &typeExpr{kind: arrayExpr, length: 2, elem: &typeExpr{kind: namedExpr, name: "int"}},
+				elem: &typeExpr{
+					kind: mapExpr,
+					key:  &typeExpr{kind: namedExpr, name: "string"},
+					elem: &typeExpr{kind: pointerExpr, elem: &typeExpr{kind: namedExpr, name: "interface"}},
+				},
+			},
+		},
+		{
+			name: "example.Pair[int,[]example.Page[string]]",
+			want: &typeExpr{kind: namedExpr, name: "example.Pair", args: []*typeExpr{
+				{kind: namedExpr, name: "int"},
+				{kind: sliceExpr, elem: &typeExpr{kind: namedExpr, name: "example.Page", args: []*typeExpr{
+					{kind: namedExpr, name: "string"},
+				}}},
+			}},
+		},
+		{
+			name: "billing.Invoice/v1",
+			want: &typeExpr{kind: namedExpr, name: "billing.Invoice/v1"},
+		},
+		{
+			name: "struct{}",
+			want: &typeExpr{kind: structExpr},
+		},
+		{
+			name: "[]struct{Name string `json:\"name\"`;example.Meta;Tags map[string]*int \"a`b\"}",
+			want: &typeExpr{kind: sliceExpr, elem: &typeExpr{kind: structExpr, fields: []structFieldExpr{
+				{name: "Name", typ: &typeExpr{kind: namedExpr, name: "string"}, tag: `json:"name"`},
+				{typ: &typeExpr{kind: namedExpr, name: "example.Meta"}},
+				{name: "Tags", typ: &typeExpr{kind: mapExpr,
+					key:  &typeExpr{kind: namedExpr, name: "string"},
+					elem: &typeExpr{kind: pointerExpr, elem: &typeExpr{kind: namedExpr, name: "int"}},
+				}, tag: "a`b"},
+			}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTypeName(tt.name)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.name, got.String())
+		})
+	}
+}
+
+func TestParseTypeNameErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset int
+		msg    string
+	}{
+		{name: "", offset: 0, msg: "unexpected end of type"},
+		{name: "*", offset: 1, msg: "unexpected end of type"},
+		{name: "[]", offset: 2, msg: "unexpected end of type"},
+		{name: "[x]int", offset: 1, msg: "expected ] or array length, found 'x'"},
+		{name: "[3int", offset: 2, msg: "expected ], found 'i'"},
+		{name: "[99999999999999999999]int", offset: 1, msg: "invalid array length"},
+		{name: "map[string", offset: 10, msg: "expected ], found end of type"},
+		{name: "map[]int", offset: 4, msg: "unexpected ']'"},
+		{name: "int]", offset: 3, msg: "unexpected ']'"},
+		{name: "int string", offset: 3, msg: "unexpected ' '"},
+		{name: "example.Page[int", offset: 16, msg: "expected , or ], found end of type"},
+		{name: "example.Page[int string]", offset: 16, msg: "expected , or ], found ' '"},
+		{name: "example.Page[]", offset: 13, msg: "unexpected ']'"},
+		{name: "struct{A int", offset: 12, msg: "expected ; or }, found end of type"},
+		{name: "struct{A int,B int}", offset: 12, msg: "expected ; or }, found ','"},
+		{name: "struct{[]A int}", offset: 7, msg: "invalid field name"},
+		{name: "struct{A int `json}", offset: 13, msg: "invalid tag"},
+		{name: "struct{A }", offset: 9, msg: "unexpected '}'"},
+		{name: strings.Repeat("*", 100) + "int", offset: 100, msg: "type nested deeper than 100"},
+		{name: strings.Repeat("map[int]", 100) + "int", offset: 796, msg: "type nested deeper than 100"},
+		{name: strings.Repeat("example.Page[", 100) + "int", offset: 1300, msg: "type nested deeper than 100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTypeName(tt.name)
+			var syntaxErr *TypeSyntaxError
+			assert.ErrorAs(t, err, &syntaxErr)
+			assert.Equal(t, tt.name, syntaxErr.Type)
+			assert.Equal(t, tt.offset, syntaxErr.Offset)
+			assert.Equal(t, tt.msg, syntaxErr.Msg)
+		})
+	}
+}
+
+func TestTypeNameRoundTrip(t *testing.T) {
+	types := []any{
+		0, "", []byte{}, []uint16{}, [2][]int{}, map[[2]int]string{}, map[TestKey][]*TestStruct{},
+		map[string]map[int]any{}, ptr(ptr(TestStatus(""))), []TestShape{}, TestIDs{}, complex64(0),
+		struct{}{}, []struct {
+			Name string `json:"name"`
+			Key  *TestKey
+		}{},
+	}
+	opts, err := applyUnmarshalOptions(RegisterType(TestKey{}), RegisterType(TestStruct{}),
+		RegisterType(TestStatus("")), RegisterInterface[TestShape](), RegisterType(TestIDs{}), WithAnonymousStructs(true),
+		WithArrays(true))
+	assert.NoError(t, err)
+	for _, v := range types {
+		typ := reflect.TypeOf(v)
+		name := typeExprOf(typ, defaultMarshalOptions).String()
+		t.Run(name, func(t *testing.T) {
+			e, err := parseTypeName(name)
+			assert.NoError(t, err)
+			assert.Equal(t, name, e.String())
+
+			resolved, err := getType(name, opts, "$")
+			assert.NoError(t, err)
+			assert.Equal(t, typ, resolved)
+		})
+	}
+}
+
+func TestUnmarshalTypeSyntaxError(t *testing.T) {
+	_, err := Unmarshal([]byte(`{"_t":"[]interface","v":[{"_t":"map[string","v":{}}]}`))
+	var syntaxErr *TypeSyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, "$.v[0]", syntaxErr.Path)
+	assert.EqualError(t, err, "invalid type \"map[string\" at $.v[0]: expected ], found end of type at offset 10")
+
+	_, err = UnmarshalAs[int]([]byte(`{"_t":"int]","v":1}`))
+	assert.EqualError(t, err, "invalid type \"int]\" at $: unexpected ']' at offset 3")
+}
+
+func TestUnmarshalDeepTypeNames(t *testing.T) {
+	// Deeply nested type names are rejected without exhausting the stack
+	data := []byte(`{"_t":"` + strings.Repeat("*", 5<<20) + `int","v":1}`)
+	_, err := Unmarshal(data)
+	var syntaxErr *TypeSyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, "type nested deeper than 100", syntaxErr.Msg)
+	assert.Equal(t, 100, syntaxErr.Offset)
+
+	// Names up to the maximum nesting can be unmarshalled
+	obj, err := Unmarshal([]byte(`{"_t":"` + strings.Repeat("*", 99) + `int","v":null}`))
+	assert.NoError(t, err)
+	assert.Nil(t, obj)
+}
+
+func TestUnmarshalLargeArrays(t *testing.T) {
+	// Arrays are only created when enabled
+	_, err := Unmarshal([]byte(`{"_t":"[]interface","v":[{"_t":"[3]int","v":[1,2,3]}]}`))
+	assert.EqualError(t, err, "invalid type \"[3]int\" at $.v[0]: arrays are not allowed, see WithArrays")
+	_, err = Unmarshal([]byte(`{"_t":"[3]int","v":[1,2,3]}`), WithArrays(true), WithArrays(false))
+	assert.EqualError(t, err, "invalid type \"[3]int\" at $: arrays are not allowed, see WithArrays")
+
+	// Arrays are rejected before they are allocated
+	_, err = Unmarshal([]byte(`{"_t":"[999999999999]int8","v":[]}`), WithArrays(true))
+	assert.EqualError(t, err, "invalid type \"[999999999999]int8\" at $: array [999999999999]int8 is larger than 1048576 bytes")
+
+	_, err = Unmarshal([]byte(`{"_t":"[]interface","v":[{"_t":"map[string]*[1024][1024]int64","v":{"a":null}}]}`),
+		WithArrays(true))
+	assert.EqualError(t, err, "invalid type \"map[string]*[1024][1024]int64\" at $.v[0]: array [1024][1024]int64 is larger than 1048576 bytes")
+
+	// Arrays up to the maximum size can be unmarshalled
+	obj, err := Unmarshal([]byte(`{"_t":"[1048576]int8","v":null}`), WithArrays(true))
+	assert.NoError(t, err)
+	assert.Equal(t, [1 << 20]int8{}, obj)
+}
+
+func TestAnonymousStructs(t *testing.T) {
+	type Point = struct {
+		X int `json:"x"`
+		Y int `json:"y,omitempty"`
+	}
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{
+			name: "Struct",
+			v:    Point{X: 1, Y: 2},
+			want: "{\"_t\":\"struct{X int `json:\\\"x\\\"`;Y int `json:\\\"y,omitempty\\\"`}\",\"v\":{\"x\":1,\"y\":2}}",
+		},
+		{
+			name: "Nested in interface",
+			v:    map[string]any{"a": []Point{{X: 1}}},
+			want: "{\"_t\":\"map[string]interface\",\"v\":{\"a\":{\"_t\":\"[]struct{X int `json:\\\"x\\\"`;Y int `json:\\\"y,omitempty\\\"`}\",\"v\":[{\"x\":1}]}}}",
+		},
+		{
+			name: "Embedded and interface fields",
+			v: struct {
+				Value any
+				TestEventMeta
+			}{Value: 1, TestEventMeta: TestEventMeta{Version: 2}},
+			want: `{"_t":"struct{Value interface;github.com/trojanc/jsonr.TestEventMeta}","v":{"Value":{"_t":"int","v":1},"version":"2"}}`,
+		},
+		{
+			name: "Empty struct",
+			v:    struct{}{},
+			want: `{"_t":"struct{}","v":{}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.v)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			obj, err := Unmarshal(data, RegisterType(TestEventMeta{}), WithAnonymousStructs(true))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.v, obj)
+		})
+	}
+}
+
+func TestAnonymousStructsErrors(t *testing.T) {
+	v := []any{struct{ A int }{1}}
+	_, err := Marshal(v, MarshalAnonymousStructs(false))
+	var unsupportedErr *json.UnsupportedTypeError
+	assert.ErrorAs(t, err, &unsupportedErr)
+	assert.Equal(t, reflect.TypeOf(v[0]), unsupportedErr.Type)
+
+	data, err := Marshal(v)
+	assert.NoError(t, err)
+	_, err = Unmarshal(data)
+	assert.EqualError(t, err, "invalid type \"struct{A int}\" at $.v[0]: anonymous structs are not allowed, see WithAnonymousStructs")
+	_, err = Unmarshal(data, WithAnonymousStructs(true), WithAnonymousStructs(false))
+	assert.EqualError(t, err, "invalid type \"struct{A int}\" at $.v[0]: anonymous structs are not allowed, see WithAnonymousStructs")
+
+	// The types of the fields should be known
+	_, err = Unmarshal([]byte(`{"_t":"struct{A example.Unknown}","v":{}}`), WithAnonymousStructs(true))
+	assert.EqualError(t, err, "unknown type \"struct{A example.Unknown}\" at $")
+
+	// Fields that can not be created, like unexported fields
+	_, err = Unmarshal([]byte(`{"_t":"struct{a int}","v":{}}`), WithAnonymousStructs(true))
+	assert.ErrorContains(t, err, "invalid type \"struct{a int}\" at $: could not create anonymous struct: ")
+}
+
+// Test generic types
+type TestPage[T any] struct {
+	Items []T `json:"items"`
+	Next  *T  `json:"next,omitempty"`
+}
+
+type TestPair[K comparable, V any] struct {
+	Key   K `json:"key"`
+	Value V `json:"value"`
+}
+
+func TestGenericTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		v        any
+		register []any
+		want     string
+	}{
+		{
+			name:     "Struct argument",
+			v:        TestPage[TestStruct]{Items: []TestStruct{{Int: 1}}},
+			register: []any{TestPage[TestStruct]{}},
+			want:     `{"_t":"github.com/trojanc/jsonr.TestPage[github.com/trojanc/jsonr.TestStruct]","v":{"items":[{"int":1}]}}`,
+		},
+		{
+			name:     "Nested generic arguments",
+			v:        TestPage[TestPair[int, []byte]]{Items: []TestPair[int, []byte]{{Key: 1, Value: []byte{1}}}},
+			register: []any{TestPage[TestPair[int, []byte]]{}},
+			want:     `{"_t":"github.com/trojanc/jsonr.TestPage[github.com/trojanc/jsonr.TestPair[int,bytes]]","v":{"items":[{"key":1,"value":"AQ=="}]}}`,
+		},
+		{
+			name:     "Interface argument",
+			v:        TestPage[any]{Items: []any{1, TestPair[string, map[string]int]{Key: "a"}}},
+			register: []any{TestPage[any]{}, TestPair[string, map[string]int]{}},
+			want:     `{"_t":"github.com/trojanc/jsonr.TestPage[interface]","v":{"items":[{"_t":"int","v":1},{"_t":"github.com/trojanc/jsonr.TestPair[string,map[string]int]","v":{"key":"a","value":null}}]}}`,
+		},
+		{
+			name:     "Composite types of generics",
+			v:        map[string][]*TestPair[TestStatus, *TestStruct]{"a": {{Key: "b"}}},
+			register: []any{TestPair[TestStatus, *TestStruct]{}},
+			want:     `{"_t":"map[string][]*github.com/trojanc/jsonr.TestPair[github.com/trojanc/jsonr.TestStatus,*github.com/trojanc/jsonr.TestStruct]","v":{"a":[{"key":"b","value":null}]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.v, MarshalTypeNames(ShortTypeNames))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			var options []UnmarshalOption
+			for _, r := range tt.register {
+				options = append(options, RegisterType(r))
+			}
+			obj, err := Unmarshal(data, options...)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.v, obj)
+		})
+	}
+}
+
+func TestGenericTypesErrors(t *testing.T) {
+	_, err := Unmarshal([]byte(`{"_t":"github.com/trojanc/jsonr.TestPage[int]","v":{}}`))
+	assert.EqualError(t, err, "unknown type \"github.com/trojanc/jsonr.TestPage[int]\" at $")
+
+	// Type arguments that have no valid type name can not be registered
+	_, err = Unmarshal(nil, RegisterType(TestPage[struct{ A int }]{}))
+	assert.EqualError(t, err, "could not apply option: invalid type name \"github.com/trojanc/jsonr.TestPage[struct { A int }]\"")
+
+	// Custom names can not have type arguments
+	_, err = Unmarshal(nil, RegisterTypeAs("test.Page[int]", TestPage[int]{}))
+	assert.EqualError(t, err, "could not apply option: invalid type name \"test.Page[int]\"")
+}
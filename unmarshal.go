@@ -1,23 +1,22 @@
 package jsonr
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
-	"strings"
-)
-
-// unwrappedType reference to the type of Unwrapped.
-var (
-	unwrappedType = reflect.TypeOf(Unwrapped{})
-	nilType       = reflect.TypeOf((*any)(nil)).Elem()
+	"slices"
+	"sort"
 )
 
 // Unwrapped a structure of an unwrapped type partially read from JSON
 type Unwrapped struct {
 	Type  string          `json:"_t"`
 	Value json.RawMessage `json:"v"`
+
+	// inline if the Value holds the members of an object that was marshalled with the type key as a sibling
+	inline bool
 }
 
 // Unmarshal decodes JSON data into a Go value with type information. It expects JSON data that was previously
@@ -48,6 +47,8 @@ type Unwrapped struct {
 //	    "John": {Name: "John", Age: 30},
 //	    "Jane": {Name: "Jane", Age: 25},
 //	})
+//
+// An *UnknownTypeError is returned when the data contains a type that is not registered.
 func Unmarshal(data []byte, options ...UnmarshalOption) (any, error) {
 
 	// Build an unmarshalOptions object from the provided options
@@ -55,22 +56,181 @@ func Unmarshal(data []byte, options ...UnmarshalOption) (any, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkLimits(data, opts); err != nil {
+		return nil, err
+	}
+	if opts.minimal {
+		return unmarshalRoot(data, opts)
+	}
 
 	// Step 1: Extract the type field
-	var wrapper Unwrapped
-	if err := json.Unmarshal(data, &wrapper); err != nil {
+	wrapper, err := readEnvelope(data, opts, "$")
+	if err != nil {
 		return nil, err
 	}
 
 	return Unwrap(wrapper, opts)
 }
 
+// UnmarshalAs decodes JSON data like Unmarshal, but returns the value as type T instead of any. The type found in
+// the data must be T, or implement T when T is an interface type, otherwise a *TypeMismatchError is returned
+// without decoding the value.
+//
+// Example usage:
+//
+//	data, _ := jsonr.Marshal(map[string]any{"person": Person{Name: "John", Age: 30}})
+//
+//	result, _ := jsonr.UnmarshalAs[map[string]any](data, jsonr.RegisterType(Person{}))
+//	// result will be map[string]any{"person": Person{Name: "John", Age: 30}}
+func UnmarshalAs[T any](data []byte, options ...UnmarshalOption) (T, error) {
+	var result T
+
+	opts, err := applyUnmarshalOptions(options...)
+	if err != nil {
+		return result, err
+	}
+	if err := checkLimits(data, opts); err != nil {
+		return result, err
+	}
+
+	target := reflect.TypeFor[T]()
+	if opts.minimal {
+		if opts.rootType != nil && opts.rootType != target {
+			return result, &TypeMismatchError{Type: opts.rootType.String(), Expected: target}
+		}
+		err := unwrapInto(data, reflect.ValueOf(&result).Elem(), opts, "$")
+		return result, err
+	}
+
+	wrapper, err := readEnvelope(data, opts, "$")
+	if err != nil {
+		return result, err
+	}
+	if wrapper.Type == "" && wrapper.Value == nil {
+		// An empty object has no type to verify
+		return result, nil
+	}
+
+	// Verify the type before decoding the value, also for a type without a value like {"_t":"string"}
+	t, err := getType(wrapper.Type, opts, "$")
+	if err != nil {
+		return result, err
+	}
+	if t == nil {
+		return result, &UnknownTypeError{Type: wrapper.Type, Path: "$"}
+	}
+	if t != target && (target.Kind() != reflect.Interface || !t.Implements(target) || !opts.isAllowed(target, t)) {
+		return result, &TypeMismatchError{Type: wrapper.Type, Expected: target}
+	}
+
+	value, err := Unwrap(wrapper, opts)
+	if err != nil || value == nil {
+		return result, err
+	}
+	return value.(T), nil
+}
+
+// UnmarshalInto decodes JSON data like Unmarshal, but stores the value in the target, which should be a non-nil
+// pointer. Like encoding/json, the data is merged into existing maps and structs of the target. The type found in
+// the data must be the type of the target, a pointer to it, or implement it when the target is an interface,
+// otherwise a *TypeMismatchError is returned without decoding the value.
+//
+// Example usage:
+//
+//	person := Person{Name: "John", Age: 30}
+//	data := []byte(`{"_t":"github.com/project/example.Person","v":{"Age":31}}`)
+//
+//	err := jsonr.UnmarshalInto(data, &person, jsonr.RegisterType(Person{}))
+//	// person will be Person{Name: "John", Age: 31}
+func UnmarshalInto(data []byte, target any, options ...UnmarshalOption) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &json.InvalidUnmarshalError{Type: reflect.TypeOf(target)}
+	}
+
+	opts, err := applyUnmarshalOptions(options...)
+	if err != nil {
+		return err
+	}
+	if err := checkLimits(data, opts); err != nil {
+		return err
+	}
+
+	v := rv.Elem()
+	if opts.minimal {
+		if opts.rootType != nil && opts.rootType != v.Type() {
+			return &TypeMismatchError{Type: opts.rootType.String(), Expected: v.Type()}
+		}
+		return unwrapInto(data, v, opts, "$")
+	}
+
+	wrapper, err := readEnvelope(data, opts, "$")
+	if err != nil {
+		return err
+	}
+	return unwrapTarget(wrapper, v, opts, "$")
+}
+
+// unwrapTarget decodes the wrapper found at the JSON path into the existing value v, after verifying that the type
+// of the wrapper can be stored in v
+func unwrapTarget(wrapper Unwrapped, v reflect.Value, opts *unmarshalOptions, path string) error {
+	t, valuePath, err := resolveWrapped(wrapper, opts, path)
+	if err != nil || t == nil {
+		return err
+	}
+
+	target := v.Type()
+	isPointer := t.Kind() == reflect.Ptr && t.Elem() == target
+	isImplementation := target.Kind() == reflect.Interface && t.Implements(target) && opts.isAllowed(target, t)
+	if t != target && !isPointer && !isImplementation {
+		return &TypeMismatchError{Type: wrapper.Type, Expected: target}
+	}
+	if valuePath == "" {
+		// Only a type without a value, like {"_t":"int"}
+		return nil
+	}
+
+	switch {
+	case t == target:
+		return unwrapInto(wrapper.Value, v, opts, valuePath)
+	case isPointer:
+		// A pointer is decoded into the value it points to, null leaves the value untouched
+		if isNull(wrapper.Value) {
+			return nil
+		}
+		return unwrapInto(wrapper.Value, v, opts, valuePath)
+	default:
+		// Like encoding/json, a non-nil pointer stored in the interface is reused
+		result := reflect.New(t).Elem()
+		if !v.IsNil() && v.Elem().Type() == t && t.Kind() == reflect.Ptr {
+			result.Set(v.Elem())
+		}
+		if err := unwrapInto(wrapper.Value, result, opts, valuePath); err != nil {
+			return err
+		}
+		v.Set(result)
+		return nil
+	}
+}
+
+// unmarshalRoot decodes data that was marshalled without type information for the root value into the root type
+func unmarshalRoot(data []byte, opts *unmarshalOptions) (any, error) {
+	if opts.rootType == nil {
+		return nil, errors.New("a root type is required to unmarshal minimal data, see WithRootType")
+	}
+	result := reflect.New(opts.rootType).Elem()
+	if err := unwrapInto(data, result, opts, "$"); err != nil {
+		return nil, err
+	}
+	return result.Interface(), nil
+}
+
 // Unwrap decodes a wrapped JSON structure back into its original Go value. It takes a Unwrapped struct containing
 // type information and raw JSON data, along with unmarshal options for type registration.
 //
-// The function handles complex types like maps and slices, including cases where values are interface{} types
-// that need recursive unwrapping. For maps and slices containing interface{} values, it creates new target
-// collections with properly unwrapped elements.
+// The function handles complex types like maps, slices and structs, including cases where values are interface{}
+// types that need recursive unwrapping. Interface values found at any depth are unwrapped using their own type
+// information.
 //
 // Example usage:
 //
@@ -83,161 +243,332 @@ func Unmarshal(data []byte, options ...UnmarshalOption) (any, error) {
 //
 // The function supports:
 // - Primitive Go types
+// - Byte slices, as base64 or hex strings
+// - Complex numbers, as [real, imag]
+// - Named types, like `type Status string` or `type IDs []int`
 // - Structs and pointers to structs
 // - Maps with primitive keys and any value type
-// - Slices of any type
+// - Slices and fixed size arrays of any type
 // - Nested combinations of the above
+//
+// Structs and maps marshalled with MarshalInline are decoded from the members next to the type.
+//
+// An *UnknownTypeError is returned when a type can not be resolved.
 func Unwrap(wrapper Unwrapped, opts *unmarshalOptions) (any, error) {
+	return unwrap(wrapper, opts, "$")
+}
 
+// unwrap decodes the wrapper found at the JSON path
+func unwrap(wrapper Unwrapped, opts *unmarshalOptions, path string) (any, error) {
+	t, valuePath, err := resolveWrapped(wrapper, opts, path)
+	if err != nil || t == nil || valuePath == "" {
+		return nil, err
+	}
+	result := reflect.New(t).Elem()
+	if err := unwrapInto(wrapper.Value, result, opts, valuePath); err != nil {
+		return nil, err
+	}
+
+	return result.Interface(), nil
+}
+
+// resolveWrapped resolves the type of the wrapper found at the JSON path, and the path of its value. A nil type is
+// returned when the wrapper has no type, and an empty path when the wrapper has a type without a value.
+func resolveWrapped(wrapper Unwrapped, opts *unmarshalOptions, path string) (reflect.Type, string, error) {
 	if wrapper.Value == nil {
-		return nil, nil
+		return nil, "", nil
+	}
+
+	t, err := getType(wrapper.Type, opts, path)
+	if err != nil {
+		return nil, "", err
+	}
+	if t == nil {
+		return nil, "", &UnknownTypeError{Type: wrapper.Type, Path: path}
+	}
+	if wrapper.inline && string(wrapper.Value) == "{}" && !canInline(t) {
+		// Only a type without a value, like {"_t":"int"}
+		return t, "", nil
 	}
 
-	instanceType := wrapper.Type
-	isPointer := strings.HasPrefix(instanceType, "*")
-	var result any
-	t := getType(instanceType, opts)
+	if wrapper.inline {
+		if !canInline(t) {
+			return nil, "", fmt.Errorf("type %s can not be inlined at %s", t, path)
+		}
+		return t, path, nil
+	}
+	return t, path + "." + opts.valueKey, nil
+}
 
-	if t.Kind() == reflect.Slice {
-		slicePtr := reflect.New(t)
-		result = slicePtr.Interface()
-		err := json.Unmarshal(wrapper.Value, result)
-		if err != nil {
-			return nil, fmt.Errorf("error unmarshalling slice: %s", err.Error())
-		}
-		if t.Elem() == unwrappedType {
-			slice := slicePtr.Elem()
-			// Create a new target map with value of any
-			targetSliceType := reflect.SliceOf(nilType)
-			targetSlice := reflect.MakeSlice(targetSliceType, slice.Len(), slice.Len())
-			targetSlicePtr := reflect.New(targetSliceType) // Pointer to map[?]any
-			targetSlicePtr.Elem().Set(targetSlice)         // Initialize the map
-
-			// Iterate over the slice
-			for i := 0; i < slice.Len(); i++ {
-				elem := slice.Index(i)
-				uw := elem.Interface().(Unwrapped)
-				unwrappedValue, err := Unwrap(uw, opts)
-				if err != nil {
-					return nil, err
-				}
-				sliceValue := reflect.ValueOf(unwrappedValue)
-				if unwrappedValue == nil {
-					sliceValue = reflect.New(nilType).Elem()
-				}
-				targetSlice.Index(i).Set(sliceValue)
-			}
-			result = targetSlicePtr.Interface()
+// readEnvelope reads the type and the raw value of the wrapped value found at the JSON path, using the field names
+// of the unmarshal options
+func readEnvelope(raw []byte, opts *unmarshalOptions, path string) (Unwrapped, error) {
+	var wrapper Unwrapped
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return wrapper, err
+	}
+	typ, ok := members[opts.typeKey]
+	if !ok {
+		if len(members) > 0 {
+			return wrapper, fmt.Errorf("missing %q at %s", opts.typeKey, path)
 		}
-	} else if t.Kind() == reflect.Map {
-		mapPtr := reflect.New(t)              // Pointer to map[string]MyStruct
-		mapPtr.Elem().Set(reflect.MakeMap(t)) // Initialize the map
-		result = mapPtr.Interface()
-		err := json.Unmarshal(wrapper.Value, result)
-		if err != nil {
-			return nil, fmt.Errorf("error unmarshalling map: %s", err.Error())
-		}
-
-		if t.Elem() == unwrappedType {
-			// Create a new target map with value of any
-			targetMapType := reflect.MapOf(t.Key(), nilType)
-			targetMap := reflect.MakeMap(targetMapType)
-			targetMapPtr := reflect.New(targetMapType) // Pointer to map[?]any
-			targetMapPtr.Elem().Set(targetMap)         // Initialize the map
-
-			iter := mapPtr.Elem().MapRange()
-			for iter.Next() {
-				keyValue := iter.Key()
-				originalValue := iter.Value()
-
-				uw := originalValue.Interface().(Unwrapped)
-				unwrappedValue, err := Unwrap(uw, opts)
-				if err != nil {
-					return nil, err
-				}
-				mapValue := reflect.ValueOf(unwrappedValue)
-				if unwrappedValue == nil {
-					mapValue = reflect.New(nilType).Elem()
-				}
-
-				targetMap.SetMapIndex(keyValue, mapValue)
-			}
-			result = targetMapPtr.Interface()
+		return wrapper, nil
+	}
+	if err := json.Unmarshal(typ, &wrapper.Type); err != nil {
+		return wrapper, fmt.Errorf("invalid %q at %s: %s", opts.typeKey, path, err.Error())
+	}
+	value, ok := members[opts.valueKey]
+	if ok && opts.strict {
+		if name, ok := unexpectedMember(members, opts.typeKey, opts.valueKey); ok {
+			return wrapper, fmt.Errorf("unexpected %q at %s", name, path)
 		}
-
-	} else {
-		result = reflect.New(t).Interface()
-		err := json.Unmarshal(wrapper.Value, result)
+	}
+	if !ok {
+		// Without a value key the other members belong to an inlined struct or map
+		delete(members, opts.typeKey)
+		data, err := json.Marshal(members)
 		if err != nil {
-			return nil, err
+			return wrapper, err
 		}
+		value = data
+		wrapper.inline = true
+	}
+	wrapper.Value = value
+	return wrapper, nil
+}
+
+// unwrapInto decodes the raw JSON found at the JSON path into the value v, unwrapping every interface value found
+// at any depth by using its own type information.
+func unwrapInto(raw json.RawMessage, v reflect.Value, opts *unmarshalOptions, path string) error {
+	t := v.Type()
+
+	// Types with their own unmarshal methods decode themselves
+	if t.Kind() != reflect.Ptr && isOpaque(t) {
+		return json.Unmarshal(raw, v.Addr().Interface())
 	}
 
-	if !isPointer {
-		if val, ok := removePointer(result); ok {
-			result = val
-		} else {
-			return nil, errors.New("could not remove pointer")
+	// Values without interfaces can be decoded directly, arrays are always walked to validate their length. In strict
+	// mode all values are walked, so that unknown fields are reported with their path.
+	if t.Kind() != reflect.Array && !needsWrapping(t) && !opts.strict {
+		if err := json.Unmarshal(raw, v.Addr().Interface()); err != nil {
+			switch t.Kind() {
+			case reflect.Slice:
+				return fmt.Errorf("error unmarshalling slice: %s", err.Error())
+			case reflect.Map:
+				return fmt.Errorf("error unmarshalling map: %s", err.Error())
+			default:
+				return err
+			}
 		}
+		return nil
 	}
 
-	return result, nil
-}
+	if isNull(raw) {
+		// Follow encoding/json where null has no effect on structs and arrays
+		if t.Kind() != reflect.Struct && t.Kind() != reflect.Array {
+			v.Set(reflect.Zero(t))
+		}
+		return nil
+	}
 
-// removePointerReflect Function to remove pointer from an `any` type variable
-// using reflection
-func removePointer(v any) (any, bool) {
-	// Use reflection to handle arbitrary pointer types
-	rv := reflect.ValueOf(v)
-	if rv.Kind() == reflect.Ptr {
-		return rv.Elem().Interface(), true
+	switch t.Kind() {
+	case reflect.Interface:
+		wrapper, err := readEnvelope(raw, opts, path)
+		if err != nil {
+			return err
+		}
+		value, err := unwrap(wrapper, opts, path)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		rv := reflect.ValueOf(value)
+		if !rv.Type().AssignableTo(t) {
+			return fmt.Errorf("type %s is not assignable to %s at %s", rv.Type(), t, path)
+		}
+		if !opts.isAllowed(t, rv.Type()) {
+			return fmt.Errorf("type %s is not an allowed implementation of %s at %s", rv.Type(), t, path)
+		}
+		v.Set(rv)
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return unwrapInto(raw, v.Elem(), opts, path)
+	case reflect.Slice:
+		if isByteSlice(t) {
+			return unmarshalBytes(raw, v, opts.bytesEncoding, path)
+		}
+		var raws []json.RawMessage
+		if err := json.Unmarshal(raw, &raws); err != nil {
+			return fmt.Errorf("error unmarshalling slice: %s", err.Error())
+		}
+		v.Set(reflect.MakeSlice(t, len(raws), len(raws)))
+		for i := range raws {
+			if err := unwrapInto(raws[i], v.Index(i), opts, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		var raws []json.RawMessage
+		if err := json.Unmarshal(raw, &raws); err != nil {
+			return fmt.Errorf("error unmarshalling array: %s", err.Error())
+		}
+		if len(raws) != t.Len() {
+			return fmt.Errorf("error unmarshalling array: expected %d elements, got %d", t.Len(), len(raws))
+		}
+		for i := range raws {
+			if err := unwrapInto(raws[i], v.Index(i), opts, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if !isTextualKey(t.Key()) {
+			return unwrapMapEntries(raw, v, opts, path)
+		}
+		var raws map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &raws); err != nil {
+			return fmt.Errorf("error unmarshalling map: %s", err.Error())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(t, len(raws)))
+		}
+		for k, r := range raws {
+			key, err := parseMapKey(k, t.Key())
+			if err != nil {
+				return err
+			}
+			elem := reflect.New(t.Elem()).Elem()
+			if err := unwrapInto(r, elem, opts, path+"."+k); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Complex64, reflect.Complex128:
+		var parts []float64
+		if err := json.Unmarshal(raw, &parts); err != nil {
+			return fmt.Errorf("error unmarshalling complex number: %s", err.Error())
+		}
+		if len(parts) != 2 {
+			return fmt.Errorf("error unmarshalling complex number: expected [real, imag], got %d elements", len(parts))
+		}
+		v.SetComplex(complex(parts[0], parts[1]))
+	case reflect.Struct:
+		if opts.stdlib && t == urlType {
+			return unmarshalURL(raw, v, opts, path)
+		}
+		return unwrapStruct(raw, v, opts, path)
+	default:
+		return json.Unmarshal(raw, v.Addr().Interface())
 	}
-	return v, false
+	return nil
 }
 
-func getType(instanceType string, opts *unmarshalOptions) reflect.Type {
-	//isPointer := strings.HasPrefix(instanceType, "*")
-	instanceType = strings.TrimPrefix(instanceType, "*")
-	var typ reflect.Type
-
-	if strings.HasPrefix(instanceType, "[]") {
-		instanceType = instanceType[2:]
-		sliceValPointer := strings.HasPrefix(instanceType, "*")
-		sliceValType := getType(instanceType, opts)
-
-		if sliceValType.Kind() == reflect.Interface {
-			sliceValType = unwrappedType // If its any, put it back into a wrapper
-		} else if sliceValPointer {
-			sliceValType = reflect.PointerTo(sliceValType)
+// unwrapMapEntries decodes a list of key/value entries into the map v, used for maps with keys that can not be
+// represented as JSON object keys
+func unwrapMapEntries(raw json.RawMessage, v reflect.Value, opts *unmarshalOptions, path string) error {
+	var entries []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return fmt.Errorf("error unmarshalling map: %s", err.Error())
+	}
+	if opts.strict {
+		for i, m := range entries {
+			if name, ok := unexpectedMember(m, opts.entryKey, opts.valueKey); ok {
+				return fmt.Errorf("unexpected %q at %s[%d]", name, path, i)
+			}
 		}
-		typ = reflect.SliceOf(sliceValType)
-	} else if strings.HasPrefix(instanceType, "map[") {
-		e := strings.Index(instanceType, "]")
-		mapKeyType := instanceType[4:e]
-		instanceType = instanceType[e+1:]
+	}
 
-		mapKeyPointer := strings.HasPrefix(mapKeyType, "*")
-		mapValPointer := strings.HasPrefix(instanceType, "*")
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, len(entries)))
+	}
+	for i, entry := range entries {
+		key := reflect.New(t.Key()).Elem()
+		if err := unwrapInto(entry[opts.entryKey], key, opts, fmt.Sprintf("%s[%d].%s", path, i, opts.entryKey)); err != nil {
+			return err
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		if err := unwrapInto(entry[opts.valueKey], elem, opts, fmt.Sprintf("%s[%d].%s", path, i, opts.valueKey)); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
 
-		// create a new map using reflection
-		kt := getType(mapKeyType, opts)
-		vt := getType(instanceType, opts)
+// unwrapStruct decodes a JSON object into the fields of the struct v, matching fields by their json names
+func unwrapStruct(raw json.RawMessage, v reflect.Value, opts *unmarshalOptions, path string) error {
+	var raws map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &raws); err != nil {
+		return fmt.Errorf("error unmarshalling struct: %s", err.Error())
+	}
 
-		if vt.Kind() == reflect.Interface {
-			vt = unwrappedType // If it's any, put it back into a wrapper
-		} else if mapValPointer {
-			vt = reflect.PointerTo(vt)
+	fields := cachedFields(v.Type())
+	for name, r := range raws {
+		f := fieldByName(fields, name)
+		if f == nil {
+			if opts.strict {
+				return fmt.Errorf("unknown field %q at %s", name, path)
+			}
+			continue
 		}
-
-		if mapKeyPointer {
-			kt = reflect.PointerTo(kt)
+		fv := fieldByIndex(v, f.index, true)
+		if !fv.IsValid() {
+			return fmt.Errorf("cannot set embedded pointer to unexported struct for field %s", f.name)
+		}
+		if f.quoted && !isNull(r) {
+			var s string
+			if err := json.Unmarshal(r, &s); err != nil {
+				return fmt.Errorf("invalid use of ,string for field %s: %s", f.name, err.Error())
+			}
+			r = json.RawMessage(s)
 		}
-		typ = reflect.MapOf(kt, vt)
-	} else {
-		if t, exists := opts.typeRegistry[instanceType]; exists {
-			typ = t
+		if err := unwrapInto(r, fv, opts, path+"."+name); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	return typ
+// unexpectedMember returns the first member in sorted order that is not one of the known members
+func unexpectedMember(members map[string]json.RawMessage, known ...string) (string, bool) {
+	var names []string
+	for name := range members {
+		if !slices.Contains(known, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", false
+	}
+	sort.Strings(names)
+	return names[0], true
+}
+
+// isNull reports if the raw JSON is a null literal
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+// getType resolves a type name as created by typeExprOf back into a reflection type. A nil type is returned for
+// unknown types, and a *TypeSyntaxError for type names that can not be parsed.
+func getType(instanceType string, opts *unmarshalOptions, path string) (reflect.Type, error) {
+	e, err := parseTypeName(instanceType)
+	if err != nil {
+		var syntaxErr *TypeSyntaxError
+		if errors.As(err, &syntaxErr) {
+			syntaxErr.Path = path
+		}
+		return nil, err
+	}
+	t, err := resolveTypeExpr(e, opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid type %q at %s: %s", instanceType, path, err)
+	}
+	return t, nil
 }
@@ -11,56 +11,293 @@ type typeRegistry map[string]reflect.Type
 
 // unmarshalOptions Options that will be used while unmarshalling the engine
 type unmarshalOptions struct {
-	// typeRegistry registry of types that can be unmarshalled
+	// registry shared registry of types that can be unmarshalled
+	registry *Registry
+	// typeRegistry registry of types that can be unmarshalled, registered for a single call
 	typeRegistry typeRegistry
+	// implementations the implementations allowed for interfaces, registered for a single call
+	implementations implementations
+	// typeKey name of the field containing the type
+	typeKey string
+	// valueKey name of the field containing the value
+	valueKey string
+	// entryKey name of the field containing the key of map entries, see WithEntryKey
+	entryKey string
+	// minimal if the data was marshalled without type information for the root value
+	minimal bool
+	// rootType type of the root value of minimal data
+	rootType reflect.Type
+	// stdlib if standard library types should be decoded from their stable encodings, see WithStdlibTypes
+	stdlib bool
+	// bytesEncoding how byte slices are read
+	bytesEncoding BytesEncoding
+	// anonymousStructs if anonymous structs should be created from their type names, see WithAnonymousStructs
+	anonymousStructs bool
+	// arrays if arrays should be created from their type names, see WithArrays
+	arrays bool
+	// limits the limits of the data, see WithMaxDepth
+	limits limits
+	// strict if unknown fields and envelope members should be rejected, see Strict
+	strict bool
 }
 
 // UnmarshalOption is a function that modifies the unmarshalOptions
 type UnmarshalOption func(*unmarshalOptions) error
 
-// RegisterType registers a type that can be unmarshalled into an instance of the given type.
+// RegisterType registers a type that can be unmarshalled into an instance of the given type. Any named type can be
+// registered, like structs, `type Status string`, `type IDs []int` or `type Lookup map[string]int`.
 func RegisterType(instance any) UnmarshalOption {
 	return func(opts *unmarshalOptions) error {
-		t := reflect.TypeOf(instance)
+		if opts.typeRegistry == nil {
+			opts.typeRegistry = make(typeRegistry)
+		}
+		return opts.typeRegistry.register(instance)
+	}
+}
+
+// RegisterTypeAs registers a type under a custom name instead of its fully qualified name, so that the type can be
+// moved or renamed without breaking data that was already marshalled. The aliases are additional names that will
+// also be unmarshalled into the type, like the name that was used before.
+//
+// Example usage:
+//
+//	jsonr.Unmarshal(data, jsonr.RegisterTypeAs("billing.Invoice/v1", Invoice{}, "github.com/project/billing.Invoice"))
+func RegisterTypeAs(name string, instance any, aliases ...string) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if opts.typeRegistry == nil {
+			opts.typeRegistry = make(typeRegistry)
+		}
+		// Names of the shared registry can not be used for other types
+		for _, n := range append([]string{name}, aliases...) {
+			if existing, exists := opts.registry.Lookup(n); exists && existing != reflect.TypeOf(instance) {
+				return fmt.Errorf("type name %q is already registered for %s", n, existing)
+			}
+		}
+		return opts.typeRegistry.registerAs(name, instance, aliases...)
+	}
+}
+
+// WithRegistry uses the types of a shared Registry while unmarshalling. Types registered with RegisterType are
+// available in addition to the types of the registry, without modifying the registry.
+func WithRegistry(registry *Registry) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if registry == nil {
+			return errors.New("registry should not be nil")
+		}
+		opts.registry = registry
+		return nil
+	}
+}
+
+// WithTypeKey sets the name of the field containing the type, the default is "_t". It should match the key used
+// with MarshalTypeKey.
+func WithTypeKey(key string) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if key == "" {
+			return errors.New("type key should not be empty")
+		}
+		opts.typeKey = key
+		return nil
+	}
+}
+
+// WithValueKey sets the name of the field containing the value, the default is "v". It should match the key used
+// with MarshalValueKey, and is also used for the values of map entries.
+func WithValueKey(key string) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if key == "" {
+			return errors.New("value key should not be empty")
+		}
+		opts.valueKey = key
+		return nil
+	}
+}
 
-		// Do not allow pointers or any other basic types to be passed in as an instance type
-		// Marshalling and Unmarshalling will take care of pointers
-		if t.Kind() != reflect.Struct {
-			return errors.New("only instance of structs should be used")
+// WithEntryKey sets the name of the field containing the key of map entries, the default is "k". It should match
+// the key used with MarshalEntryKey.
+func WithEntryKey(key string) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if key == "" {
+			return errors.New("entry key should not be empty")
 		}
+		opts.entryKey = key
+		return nil
+	}
+}
 
-		typeKey := fmt.Sprintf("%s.%s", t.PkgPath(), t.Name())
-		opts.typeRegistry[typeKey] = t
+// WithMinimal unmarshals data that was marshalled with MarshalMinimal, where the root value has no type information.
+// The type of the root value is provided by WithRootType, or the type parameter of UnmarshalAs.
+func WithMinimal() UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		opts.minimal = true
 		return nil
 	}
 }
 
+// WithRootType unmarshals data that was marshalled with MarshalMinimal into a value of the type of the instance.
+// Any type can be used as root type, also types that are not registered like []Person.
+//
+// Example usage:
+//
+//	data, _ := jsonr.Marshal([]Person{{Name: "John"}}, jsonr.MarshalMinimal())
+//	// data will be [{"Name":"John"}]
+//	result, _ := jsonr.Unmarshal(data, jsonr.WithRootType([]Person{}))
+//	// result will be []Person{{Name: "John"}}
+func WithRootType(instance any) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		if instance == nil {
+			return errors.New("root type should not be nil")
+		}
+		opts.minimal = true
+		opts.rootType = reflect.TypeOf(instance)
+		return nil
+	}
+}
+
+// WithArrays specifies if arrays should be created from their type names, like [3]int, the default is false. Every
+// distinct length in the data creates a new type that is never released, so it should only be enabled for data from
+// trusted sources. When disabled, data naming arrays fails to unmarshal. Arrays that are fields of registered types
+// are not affected, and named array types like type ID [16]byte can be registered instead.
+func WithArrays(on bool) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		opts.arrays = on
+		return nil
+	}
+}
+
+// WithAnonymousStructs specifies if anonymous structs should be created from the fields described by their type
+// name, the default is false. Every distinct anonymous struct in the data creates a new type that is never released,
+// so it should only be enabled for data from trusted sources. When disabled, data with anonymous structs fails to
+// unmarshal. The types of the fields should be known, like any other type.
+func WithAnonymousStructs(on bool) UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		opts.anonymousStructs = on
+		return nil
+	}
+}
+
+// Strict rejects data that does not match the registered types exactly, for contract testing between services.
+// Like json.Decoder.DisallowUnknownFields, members of JSON objects that do not match a field of the struct are
+// rejected, as are envelopes with members other than the type key and value key.
+func Strict() UnmarshalOption {
+	return func(opts *unmarshalOptions) error {
+		opts.strict = true
+		return nil
+	}
+}
+
+// lookup finds a registered type by its name
+func (opts *unmarshalOptions) lookup(name string) (reflect.Type, bool) {
+	if t, exists := opts.typeRegistry[name]; exists {
+		return t, true
+	}
+	return opts.registry.Lookup(name)
+}
+
+// isAllowed reports if the type t can be unmarshalled into the interface. When implementations are registered for
+// the interface, t should be one of them, see RegisterInterface.
+func (opts *unmarshalOptions) isAllowed(iface, t reflect.Type) bool {
+	local, shared := opts.implementations[iface], opts.registry.implementationsOf(iface)
+	if len(local) == 0 && len(shared) == 0 {
+		return true
+	}
+	return isImplementation(local, t) || isImplementation(shared, t)
+}
+
+// register adds the type of the instance to the registry
+func (r typeRegistry) register(instance any) error {
+	return r.registerType(reflect.TypeOf(instance))
+}
+
+// registerType adds the type to the registry
+func (r typeRegistry) registerType(t reflect.Type) error {
+	// Do not allow pointers or any other unnamed types to be passed in as an instance type
+	// Marshalling and Unmarshalling will take care of pointers
+	if !isRegistrable(t) {
+		return errors.New("only instance of named types should be used")
+	}
+
+	name, err := registeredName(t)
+	if err != nil {
+		return err
+	}
+	return r.add(name, t)
+}
+
+// registeredName returns the name a named type is registered with. Generic types are registered with their type
+// arguments, which should be valid type names themselves.
+func registeredName(t reflect.Type) (string, error) {
+	name := FullTypeNames(t)
+	if e, err := parseTypeName(name); err != nil || e.kind != namedExpr {
+		return "", fmt.Errorf("invalid type name %q", name)
+	}
+	return name, nil
+}
+
+// registerAs adds the type of the instance to the registry with a custom name and aliases
+func (r typeRegistry) registerAs(name string, instance any, aliases ...string) error {
+	t := reflect.TypeOf(instance)
+	if !isRegistrable(t) {
+		return errors.New("only instance of named types should be used")
+	}
+
+	for _, n := range append([]string{name}, aliases...) {
+		if !isValidTypeName(n) {
+			return fmt.Errorf("invalid type name %q", n)
+		}
+		if err := r.add(n, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clone returns a copy of the registry
+func (r typeRegistry) clone() typeRegistry {
+	c := make(typeRegistry, len(r))
+	for n, t := range r {
+		c[n] = t
+	}
+	return c
+}
+
+// add adds the type with the name to the registry, a name can only be used by a single type
+func (r typeRegistry) add(name string, t reflect.Type) error {
+	if existing, exists := r[name]; exists && existing != t {
+		return fmt.Errorf("type name %q is already registered for %s", name, existing)
+	}
+	r[name] = t
+	return nil
+}
+
+// isValidTypeName reports if the name can be used as a custom type name without being confused with other type
+// names, it should be a single named type without type arguments
+func isValidTypeName(name string) bool {
+	e, err := parseTypeName(name)
+	return err == nil && e.kind == namedExpr && len(e.args) == 0
+}
+
+// isRegistrable reports if the type is a named type that can be registered
+func isRegistrable(t reflect.Type) bool {
+	if t == nil || t.Name() == "" || t.PkgPath() == "" {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return false
+	default:
+		return true
+	}
+}
+
 // applyUnmarshalOptions Applies the given options and returns the applied unmarshalOptions
 func applyUnmarshalOptions(options ...UnmarshalOption) (*unmarshalOptions, error) {
 	opts := &unmarshalOptions{
-		typeRegistry: make(map[string]reflect.Type),
-	}
-
-	// Add the primitive types to the type registry
-	opts.typeRegistry["int"] = reflect.TypeOf(int(0))
-	opts.typeRegistry["int8"] = reflect.TypeOf(int8(0))
-	opts.typeRegistry["int16"] = reflect.TypeOf(int16(0))
-	opts.typeRegistry["int32"] = reflect.TypeOf(int32(0))
-	opts.typeRegistry["int64"] = reflect.TypeOf(int64(0))
-	opts.typeRegistry["uint"] = reflect.TypeOf(uint(0))
-	opts.typeRegistry["uint8"] = reflect.TypeOf(uint8(0))
-	opts.typeRegistry["uint16"] = reflect.TypeOf(uint16(0))
-	opts.typeRegistry["uint32"] = reflect.TypeOf(uint32(0))
-	opts.typeRegistry["uint64"] = reflect.TypeOf(uint64(0))
-	opts.typeRegistry["float32"] = reflect.TypeOf(float32(0))
-	opts.typeRegistry["float64"] = reflect.TypeOf(float64(0))
-	opts.typeRegistry["complex64"] = reflect.TypeOf(complex64(0))
-	opts.typeRegistry["complex128"] = reflect.TypeOf(complex128(0))
-	opts.typeRegistry["bool"] = reflect.TypeOf(false)
-	opts.typeRegistry["string"] = reflect.TypeOf("")
-	opts.typeRegistry["byte"] = reflect.TypeOf(byte(0))
-	opts.typeRegistry["rune"] = reflect.TypeOf(rune(0))
-	opts.typeRegistry["interface"] = reflect.TypeOf(new(any)).Elem()
+		registry: primitiveRegistry,
+		typeKey:  "_t",
+		valueKey: "v",
+		entryKey: "k",
+	}
 
 	for _, o := range options {
 		err := o(opts)
@@ -68,6 +305,12 @@ func applyUnmarshalOptions(options ...UnmarshalOption) (*unmarshalOptions, error
 			return nil, fmt.Errorf("could not apply option: %s", err.Error())
 		}
 	}
+	if opts.typeKey == opts.valueKey {
+		return nil, fmt.Errorf("type key and value key should not be the same: %q", opts.typeKey)
+	}
+	if opts.entryKey == opts.valueKey {
+		return nil, fmt.Errorf("entry key and value key should not be the same: %q", opts.entryKey)
+	}
 
 	return opts, nil
 }
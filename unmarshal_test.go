@@ -1,8 +1,11 @@
 package jsonr
 
 import (
+	"encoding"
+	"encoding/json"
 	"errors"
 	"github.com/stretchr/testify/assert"
+	"reflect"
 	"testing"
 )
 
@@ -38,7 +41,79 @@ func TestUnmarshal(t *testing.T) {
 				},
 			},
 			wantErr: assert.Error,
-			errStr:  "could not apply option: only instance of structs should be used",
+			errStr:  "could not apply option: only instance of named types should be used",
+		},
+		{
+			name: "Register builtin type",
+			args: args{
+				options: []UnmarshalOption{
+					RegisterType("string"),
+				},
+			},
+			wantErr: assert.Error,
+			errStr:  "could not apply option: only instance of named types should be used",
+		},
+		{
+			name: "Register nil",
+			args: args{
+				options: []UnmarshalOption{
+					RegisterType(nil),
+				},
+			},
+			wantErr: assert.Error,
+			errStr:  "could not apply option: only instance of named types should be used",
+		},
+		{
+			name: "Nil registry",
+			args: args{
+				options: []UnmarshalOption{
+					WithRegistry(nil),
+				},
+			},
+			wantErr: assert.Error,
+			errStr:  "could not apply option: registry should not be nil",
+		},
+		{
+			name: "Register type with invalid name",
+			args: args{
+				options: []UnmarshalOption{
+					RegisterTypeAs("test struct", TestStruct{}),
+				},
+			},
+			wantErr: assert.Error,
+			errStr:  "could not apply option: invalid type name \"test struct\"",
+		},
+		{
+			name: "Register type with name of other type",
+			args: args{
+				options: []UnmarshalOption{
+					RegisterTypeAs("test.Struct", TestStruct{}),
+					RegisterTypeAs("test.Struct", TestStructPtrs{}),
+				},
+			},
+			wantErr: assert.Error,
+			errStr:  "could not apply option: type name \"test.Struct\" is already registered for jsonr.TestStruct",
+		},
+		{
+			name: "Register type with primitive name",
+			args: args{
+				options: []UnmarshalOption{
+					RegisterTypeAs("test.Struct", TestStruct{}, "string"),
+				},
+			},
+			wantErr: assert.Error,
+			errStr:  "could not apply option: type name \"string\" is already registered for string",
+		},
+		{
+			name: "Register type with name",
+			args: args{
+				data: []byte(`{"_t":"[]test.Struct/v0","v":[{"int":1}]}`),
+				options: []UnmarshalOption{
+					RegisterTypeAs("test.Struct/v1", TestStruct{}, "test.Struct/v0"),
+				},
+			},
+			wantErr: assert.NoError,
+			want:    []TestStruct{{Int: 1}},
 		},
 		{
 			name: "Broken data",
@@ -80,6 +155,256 @@ func TestUnmarshal(t *testing.T) {
 			wantErr: assert.Error,
 			errStr:  "json: cannot unmarshal number into Go value of type string",
 		},
+		{
+			name: "Broken struct",
+			args: args{
+				data:    []byte(`{"_t":"github.com/trojanc/jsonr.TestEvent","v":{"name":1}}`),
+				options: []UnmarshalOption{RegisterType(TestEvent{})},
+			},
+			wantErr: assert.Error,
+			errStr:  "json: cannot unmarshal number into Go value of type string",
+		},
+		{
+			name: "Broken struct any field",
+			args: args{
+				data:    []byte(`{"_t":"github.com/trojanc/jsonr.TestEvent","v":{"payload":{"_t":"int","v":"1"}}}`),
+				options: []UnmarshalOption{RegisterType(TestEvent{})},
+			},
+			wantErr: assert.Error,
+			errStr:  "json: cannot unmarshal string into Go value of type int",
+		},
+		{
+			name: "Invalid map key",
+			args: args{
+				data: []byte(`{"_t":"map[int]interface","v":{"a":null}}`),
+			},
+			wantErr: assert.Error,
+			errStr:  "invalid map key \"a\" for int",
+		},
+		{
+			name: "Overflowing map key",
+			args: args{
+				data: []byte(`{"_t":"map[int8]interface","v":{"300":null}}`),
+			},
+			wantErr: assert.Error,
+			errStr:  "invalid map key \"300\" for int8",
+		},
+		{
+			name: "Too few array elements",
+			args: args{
+				data:    []byte(`{"_t":"[3]int","v":[1,2]}`),
+				options: []UnmarshalOption{WithArrays(true)},
+			},
+			wantErr: assert.Error,
+			errStr:  "error unmarshalling array: expected 3 elements, got 2",
+		},
+		{
+			name: "Too many array elements",
+			args: args{
+				data:    []byte(`{"_t":"[1]interface","v":[null,null]}`),
+				options: []UnmarshalOption{WithArrays(true)},
+			},
+			wantErr: assert.Error,
+			errStr:  "error unmarshalling array: expected 1 elements, got 2",
+		},
+		{
+			name: "Unregistered struct",
+			args: args{
+				data: []byte(`{"_t":"github.com/trojanc/jsonr.TestStruct","v":{}}`),
+			},
+			wantErr: assert.Error,
+			errStr:  "unknown type \"github.com/trojanc/jsonr.TestStruct\" at $",
+		},
+		{
+			name: "Misspelled type",
+			args: args{
+				data: []byte(`{"_t":"*strnig","v":"a"}`),
+			},
+			wantErr: assert.Error,
+			errStr:  "unknown type \"*strnig\" at $",
+		},
+		{
+			name: "Unknown map key type",
+			args: args{
+				data: []byte(`{"_t":"map[example.Key]int","v":[]}`),
+			},
+			wantErr: assert.Error,
+			errStr:  "unknown type \"map[example.Key]int\" at $",
+		},
+		{
+			name: "Unknown type in slice",
+			args: args{
+				data: []byte(`{"_t":"[]interface","v":[null,{"_t":"example.Person","v":{}}]}`),
+			},
+			wantErr: assert.Error,
+			errStr:  "unknown type \"example.Person\" at $.v[1]",
+		},
+		{
+			name: "Unknown type in map",
+			args: args{
+				data: []byte(`{"_t":"map[string]interface","v":{"a":{"_t":"[]example.Person","v":[]}}}`),
+			},
+			wantErr: assert.Error,
+			errStr:  "unknown type \"[]example.Person\" at $.v.a",
+		},
+		{
+			name: "Unknown type in struct field",
+			args: args{
+				data:    []byte(`{"_t":"github.com/trojanc/jsonr.TestEvent","v":{"items":[{"_t":"example.Person","v":{}}]}}`),
+				options: []UnmarshalOption{RegisterType(TestEvent{})},
+			},
+			wantErr: assert.Error,
+			errStr:  "unknown type \"example.Person\" at $.v.items[0]",
+		},
+		{
+			name: "Type without value",
+			args: args{
+				data: []byte(`{"_t":"int"}`),
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "Inline type that can not be inlined",
+			args: args{
+				data: []byte(`{"_t":"[]interface","v":[{"_t":"[]int","a":1}]}`),
+			},
+			wantErr: assert.Error,
+			errStr:  "type []int can not be inlined at $.v[0]",
+		},
+		{
+			name: "Inline struct with invalid member",
+			args: args{
+				data:    []byte(`{"_t":"github.com/trojanc/jsonr.TestStruct","int":"a"}`),
+				options: []UnmarshalOption{RegisterType(TestStruct{})},
+			},
+			wantErr: assert.Error,
+			errStr:  "json: cannot unmarshal string into Go struct field TestStruct.int of type int",
+		},
+		{
+			name: "Minimal without root type",
+			args: args{
+				data:    []byte(`{"int":1}`),
+				options: []UnmarshalOption{WithMinimal()},
+			},
+			wantErr: assert.Error,
+			errStr:  "a root type is required to unmarshal minimal data, see WithRootType",
+		},
+		{
+			name: "Nil root type",
+			args: args{
+				options: []UnmarshalOption{WithRootType(nil)},
+			},
+			wantErr: assert.Error,
+			errStr:  "could not apply option: root type should not be nil",
+		},
+		{
+			name: "Unknown type in minimal data",
+			args: args{
+				data:    []byte(`[{"_t":"example.Person","v":{}}]`),
+				options: []UnmarshalOption{WithRootType([]any{})},
+			},
+			wantErr: assert.Error,
+			errStr:  "unknown type \"example.Person\" at $[0]",
+		},
+		{
+			name: "Complex number with missing part",
+			args: args{
+				data: []byte(`{"_t":"[]complex128","v":[[1]]}`),
+			},
+			wantErr: assert.Error,
+			errStr:  "error unmarshalling complex number: expected [real, imag], got 1 elements",
+		},
+		{
+			name: "Complex number that is not an array",
+			args: args{
+				data: []byte(`{"_t":"complex64","v":"1+2i"}`),
+			},
+			wantErr: assert.Error,
+			errStr:  "error unmarshalling complex number: json: cannot unmarshal string into Go value of type []float64",
+		},
+		{
+			name: "Custom type and value keys",
+			args: args{
+				data:    []byte(`{"@type":"[]interface","@value":[{"@type":"int","@value":1},{"@type":"string","@value":"a"}]}`),
+				options: []UnmarshalOption{WithTypeKey("@type"), WithValueKey("@value")},
+			},
+			wantErr: assert.NoError,
+			want:    []any{1, "a"},
+		},
+		{
+			name: "Unknown type with custom value key",
+			args: args{
+				data:    []byte(`{"$type":"map[string]interface","$value":{"a":{"$type":"example.Person","$value":{}}}}`),
+				options: []UnmarshalOption{WithTypeKey("$type"), WithValueKey("$value")},
+			},
+			wantErr: assert.Error,
+			errStr:  "unknown type \"example.Person\" at $.$value.a",
+		},
+		{
+			name: "Missing custom type key",
+			args: args{
+				data:    []byte(`{"_t":"int","v":1}`),
+				options: []UnmarshalOption{WithTypeKey("$type")},
+			},
+			wantErr: assert.Error,
+			errStr:  "missing \"$type\" at $",
+		},
+		{
+			name: "Missing nested type key",
+			args: args{
+				data: []byte(`{"_t":"[]interface","v":[{"type":"int","v":1}]}`),
+			},
+			wantErr: assert.Error,
+			errStr:  "missing \"_t\" at $.v[0]",
+		},
+		{
+			name: "Invalid type",
+			args: args{
+				data: []byte(`{"_t":1,"v":1}`),
+			},
+			wantErr: assert.Error,
+			errStr:  "invalid \"_t\" at $: json: cannot unmarshal number into Go value of type string",
+		},
+		{
+			name: "Empty type key",
+			args: args{
+				options: []UnmarshalOption{WithTypeKey("")},
+			},
+			wantErr: assert.Error,
+			errStr:  "could not apply option: type key should not be empty",
+		},
+		{
+			name: "Empty value key",
+			args: args{
+				options: []UnmarshalOption{WithValueKey("")},
+			},
+			wantErr: assert.Error,
+			errStr:  "could not apply option: value key should not be empty",
+		},
+		{
+			name: "Same type and value key",
+			args: args{
+				options: []UnmarshalOption{WithValueKey("_t")},
+			},
+			wantErr: assert.Error,
+			errStr:  "type key and value key should not be the same: \"_t\"",
+		},
+		{
+			name: "Empty entry key",
+			args: args{
+				options: []UnmarshalOption{WithEntryKey("")},
+			},
+			wantErr: assert.Error,
+			errStr:  "could not apply option: entry key should not be empty",
+		},
+		{
+			name: "Same entry and value key",
+			args: args{
+				options: []UnmarshalOption{WithEntryKey("v")},
+			},
+			wantErr: assert.Error,
+			errStr:  "entry key and value key should not be the same: \"v\"",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -92,3 +417,270 @@ func TestUnmarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalUnknownTypeError(t *testing.T) {
+	_, err := Unmarshal([]byte(`{"_t":"map[string]interface","v":{"person":{"_t":"example.Person","v":{}}}}`))
+
+	var unknownTypeErr *UnknownTypeError
+	assert.ErrorAs(t, err, &unknownTypeErr)
+	assert.Equal(t, "example.Person", unknownTypeErr.Type)
+	assert.Equal(t, "$.v.person", unknownTypeErr.Path)
+
+	// Inlined values without members still need a known type
+	_, err = Unmarshal([]byte(`{"_t":"nope.Missing"}`))
+	assert.EqualError(t, err, "unknown type \"nope.Missing\" at $")
+	_, err = Unmarshal([]byte(`{"_t":"[]interface","v":[{"_t":"int"},{"_t":"nope.Missing"}]}`))
+	assert.EqualError(t, err, "unknown type \"nope.Missing\" at $.v[1]")
+}
+
+func TestUnmarshalAs(t *testing.T) {
+	data, err := Marshal(map[string]any{"a": TestStruct{Int: 1}})
+	assert.NoError(t, err)
+
+	m, err := UnmarshalAs[map[string]any](data, RegisterType(TestStruct{}))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": TestStruct{Int: 1}}, m)
+
+	// Interface types accept any type that implements them
+	a, err := UnmarshalAs[any](data, RegisterType(TestStruct{}))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": TestStruct{Int: 1}}, a)
+
+	data, err = Marshal(TestTextKey{Tenant: "a", Region: "eu"})
+	assert.NoError(t, err)
+	tm, err := UnmarshalAs[encoding.TextMarshaler](data, RegisterType(TestTextKey{}))
+	assert.NoError(t, err)
+	assert.Equal(t, TestTextKey{Tenant: "a", Region: "eu"}, tm)
+
+	// Pointers are kept
+	data, err = Marshal(&TestStruct{Int: 1})
+	assert.NoError(t, err)
+	p, err := UnmarshalAs[*TestStruct](data, RegisterType(TestStruct{}))
+	assert.NoError(t, err)
+	assert.Equal(t, &TestStruct{Int: 1}, p)
+
+	// nil gives the zero value
+	data, err = Marshal(nil)
+	assert.NoError(t, err)
+	p, err = UnmarshalAs[*TestStruct](data)
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+}
+
+func TestUnmarshalAsErrors(t *testing.T) {
+	data, err := Marshal(&TestStruct{Int: 1})
+	assert.NoError(t, err)
+
+	_, err = UnmarshalAs[TestStruct](data, RegisterType(TestStruct{}))
+	var mismatchErr *TypeMismatchError
+	assert.ErrorAs(t, err, &mismatchErr)
+	assert.Equal(t, "*github.com/trojanc/jsonr.TestStruct", mismatchErr.Type)
+	assert.Equal(t, reflect.TypeOf(TestStruct{}), mismatchErr.Expected)
+	assert.EqualError(t, err, "type \"*github.com/trojanc/jsonr.TestStruct\" can not be unmarshalled as jsonr.TestStruct")
+
+	_, err = UnmarshalAs[encoding.TextMarshaler](data, RegisterType(TestStruct{}))
+	assert.EqualError(t, err, "type \"*github.com/trojanc/jsonr.TestStruct\" can not be unmarshalled as encoding.TextMarshaler")
+
+	// Unnamed types are not converted to named types
+	data, err = Marshal([]int{1})
+	assert.NoError(t, err)
+	_, err = UnmarshalAs[TestIDs](data)
+	assert.EqualError(t, err, "type \"[]int\" can not be unmarshalled as jsonr.TestIDs")
+
+	_, err = UnmarshalAs[TestStruct](data)
+	assert.EqualError(t, err, "type \"[]int\" can not be unmarshalled as jsonr.TestStruct")
+
+	_, err = UnmarshalAs[TestStruct](data, RegisterType(func() {}))
+	assert.EqualError(t, err, "could not apply option: only instance of named types should be used")
+
+	_, err = UnmarshalAs[TestStruct]([]byte(`{`))
+	assert.EqualError(t, err, "unexpected end of JSON input")
+
+	_, err = UnmarshalAs[TestStruct]([]byte(`{"_t":"github.com/trojanc/jsonr.TestStruct","v":{}}`))
+	assert.EqualError(t, err, "unknown type \"github.com/trojanc/jsonr.TestStruct\" at $")
+
+	// Types without a value are verified too
+	_, err = UnmarshalAs[int]([]byte(`{"_t":"string"}`))
+	assert.EqualError(t, err, "type \"string\" can not be unmarshalled as int")
+	_, err = UnmarshalAs[int]([]byte(`{"_t":"nope.Missing"}`))
+	assert.EqualError(t, err, "unknown type \"nope.Missing\" at $")
+	v, err := UnmarshalAs[int]([]byte(`{"_t":"int"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, v)
+	v, err = UnmarshalAs[int]([]byte(`{}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, v)
+}
+
+func TestUnmarshalAsMinimal(t *testing.T) {
+	data, err := Marshal([]TestEvent{{Name: "a", Payload: 1}}, MarshalMinimal())
+	assert.NoError(t, err)
+
+	result, err := UnmarshalAs[[]TestEvent](data, WithMinimal())
+	assert.NoError(t, err)
+	assert.Equal(t, []TestEvent{{Name: "a", Payload: 1}}, result)
+
+	result, err = UnmarshalAs[[]TestEvent](data, WithRootType([]TestEvent{}))
+	assert.NoError(t, err)
+	assert.Equal(t, []TestEvent{{Name: "a", Payload: 1}}, result)
+
+	_, err = UnmarshalAs[[]TestEvent](data, WithRootType([]TestStruct{}))
+	assert.EqualError(t, err, "type \"[]jsonr.TestStruct\" can not be unmarshalled as []jsonr.TestEvent")
+}
+
+func TestUnmarshalInto(t *testing.T) {
+	// Structs are merged
+	event := TestEvent{Name: "a", Payload: 1}
+	data := []byte(`{"_t":"github.com/trojanc/jsonr.TestEvent","v":{"items":[{"_t":"string","v":"b"}]}}`)
+	assert.NoError(t, UnmarshalInto(data, &event, RegisterType(TestEvent{})))
+	assert.Equal(t, TestEvent{Name: "a", Payload: 1, Items: []any{"b"}}, event)
+
+	// Maps are merged
+	bag := TestBag{"a": 1}
+	data, err := Marshal(TestBag{"b": TestStruct{Int: 2}})
+	assert.NoError(t, err)
+	assert.NoError(t, UnmarshalInto(data, &bag, RegisterType(TestBag{}), RegisterType(TestStruct{})))
+	assert.Equal(t, TestBag{"a": 1, "b": TestStruct{Int: 2}}, bag)
+
+	// Slices are replaced
+	items := []any{1, 2, 3}
+	data, err = Marshal([]any{"a"})
+	assert.NoError(t, err)
+	assert.NoError(t, UnmarshalInto(data, &items))
+	assert.Equal(t, []any{"a"}, items)
+
+	// Pointers are decoded into the value they point to
+	s := TestStruct{String: "a"}
+	data, err = Marshal(&TestStruct{Int: 1})
+	assert.NoError(t, err)
+	assert.NoError(t, UnmarshalInto(data, &s, RegisterType(TestStruct{})))
+	assert.Equal(t, TestStruct{String: "a", Int: 1}, s)
+
+	// Interfaces hold any implementing type, reusing pointers
+	var v any = &TestStruct{String: "a"}
+	held := v
+	assert.NoError(t, UnmarshalInto(data, &v, RegisterType(TestStruct{})))
+	assert.Same(t, held, v)
+	assert.Equal(t, &TestStruct{String: "a", Int: 1}, v)
+
+	var marshaler json.Marshaler
+	data, err = Marshal(TestTextKey{Tenant: "a"})
+	assert.NoError(t, err)
+	err = UnmarshalInto(data, &marshaler, RegisterType(TestTextKey{}))
+	assert.EqualError(t, err, "type \"github.com/trojanc/jsonr.TestTextKey\" can not be unmarshalled as json.Marshaler")
+
+	// Null values reset the target
+	bag = TestBag{"a": 1}
+	assert.NoError(t, UnmarshalInto([]byte(`{"_t":"github.com/trojanc/jsonr.TestBag","v":null}`), &bag, RegisterType(TestBag{})))
+	assert.Nil(t, bag)
+
+	// Minimal data is decoded into the target type
+	event = TestEvent{Name: "a"}
+	assert.NoError(t, UnmarshalInto([]byte(`{"payload":{"_t":"int","v":1}}`), &event, WithMinimal()))
+	assert.Equal(t, TestEvent{Name: "a", Payload: 1}, event)
+}
+
+func TestUnmarshalIntoErrors(t *testing.T) {
+	data, err := Marshal(TestStruct{Int: 1})
+	assert.NoError(t, err)
+
+	var s TestStruct
+	assert.EqualError(t, UnmarshalInto(data, s), "json: Unmarshal(non-pointer jsonr.TestStruct)")
+	assert.EqualError(t, UnmarshalInto(data, nil), "json: Unmarshal(nil)")
+	assert.EqualError(t, UnmarshalInto(data, (*TestStruct)(nil)), "json: Unmarshal(nil *jsonr.TestStruct)")
+
+	assert.EqualError(t, UnmarshalInto(data, &s), "unknown type \"github.com/trojanc/jsonr.TestStruct\" at $")
+	assert.EqualError(t, UnmarshalInto(data, &s, RegisterType(func() {})), "could not apply option: only instance of named types should be used")
+	assert.EqualError(t, UnmarshalInto([]byte(`{`), &s), "unexpected end of JSON input")
+
+	var event TestEvent
+	err = UnmarshalInto(data, &event, RegisterType(TestStruct{}))
+	var mismatchErr *TypeMismatchError
+	assert.ErrorAs(t, err, &mismatchErr)
+	assert.EqualError(t, err, "type \"github.com/trojanc/jsonr.TestStruct\" can not be unmarshalled as jsonr.TestEvent")
+
+	err = UnmarshalInto([]byte(`{}`), &event, WithRootType(TestStruct{}))
+	assert.EqualError(t, err, "type \"jsonr.TestStruct\" can not be unmarshalled as jsonr.TestEvent")
+
+	// Types without a value are verified too
+	i := 1
+	err = UnmarshalInto([]byte(`{"_t":"string"}`), &i)
+	assert.EqualError(t, err, "type \"string\" can not be unmarshalled as int")
+	assert.NoError(t, UnmarshalInto([]byte(`{"_t":"int"}`), &i))
+	assert.Equal(t, 1, i)
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "Unknown field of a walked struct",
+			data: `{"_t":"github.com/trojanc/jsonr.TestEvent","v":{"name":"a","extra":1}}`,
+			err:  "unknown field \"extra\" at $.v",
+		},
+		{
+			name: "Unknown field of a nested struct",
+			data: `{"_t":"[]interface","v":[{"_t":"github.com/trojanc/jsonr.TestStruct","v":{"int":1,"extra":1}}]}`,
+			err:  "unknown field \"extra\" at $.v[0].v",
+		},
+		{
+			name: "Unknown field of a struct field",
+			data: `{"_t":"github.com/trojanc/jsonr.TestEvent","v":{"name":"a","nested":{"name":"b","extra":1}}}`,
+			err:  "unknown field \"extra\" at $.v.nested",
+		},
+		{
+			name: "Unknown field of a slice element",
+			data: `{"_t":"[]github.com/trojanc/jsonr.TestStruct","v":[{"int":1},{"extra":1}]}`,
+			err:  "unknown field \"extra\" at $.v[1]",
+		},
+		{
+			name: "Unknown field of an inlined struct",
+			data: `{"_t":"github.com/trojanc/jsonr.TestStruct","int":1,"extra":1}`,
+			err:  "unknown field \"extra\" at $",
+		},
+		{
+			name: "Unexpected envelope member",
+			data: `{"_t":"int","v":1,"x":2,"a":3}`,
+			err:  "unexpected \"a\" at $",
+		},
+		{
+			name: "Unexpected nested envelope member",
+			data: `{"_t":"map[string]interface","v":{"a":{"_t":"int","v":1,"x":2}}}`,
+			err:  "unexpected \"x\" at $.v.a",
+		},
+		{
+			name: "Unexpected map entry member",
+			data: `{"_t":"map[[2]int]string","v":[{"k":[1,2],"v":"a","x":1}]}`,
+			err:  "unexpected \"x\" at $.v[0]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := []UnmarshalOption{RegisterType(TestEvent{}), RegisterType(TestStruct{}), WithArrays(true)}
+			_, err := Unmarshal([]byte(tt.data), options...)
+			assert.NoError(t, err)
+
+			_, err = Unmarshal([]byte(tt.data), append(options, Strict())...)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+
+	// Data matching the types is accepted
+	data, err := Marshal(map[string]any{"event": TestEvent{Name: "a", Payload: TestStruct{Int: 1}}})
+	assert.NoError(t, err)
+	_, err = Unmarshal(data, RegisterType(TestEvent{}), RegisterType(TestStruct{}), Strict())
+	assert.NoError(t, err)
+
+	// Data after the value is always rejected
+	_, err = Unmarshal([]byte(`{"_t":"int","v":1} {}`))
+	assert.EqualError(t, err, "invalid character '{' after top-level value")
+	_, err = Unmarshal([]byte(`{"_t":"int","v":1} {}`), Strict())
+	assert.EqualError(t, err, "invalid character '{' after top-level value")
+
+	var event TestEvent
+	err = UnmarshalInto([]byte(`{"_t":"github.com/trojanc/jsonr.TestEvent","v":{"extra":1}}`), &event, RegisterType(TestEvent{}), Strict())
+	assert.EqualError(t, err, "unknown field \"extra\" at $.v")
+}